@@ -0,0 +1,32 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffWords(t *testing.T) {
+	testCases := []struct {
+		old, new string
+		expected []DiffOp
+	}{
+		{
+			"Ten killed in road accident",
+			"Twelve killed in road accident in Dhaka",
+			[]DiffOp{{DiffDelete, "Ten"}, {DiffInsert, "Twelve"}, {DiffEqual, "killed in road accident"}, {DiffInsert, "in Dhaka"}},
+		},
+		{
+			"ঢাকায় ভারী বৃষ্টি",
+			"ঢাকায় বৃষ্টি",
+			[]DiffOp{{DiffEqual, "ঢাকায়"}, {DiffDelete, "ভারী"}, {DiffEqual, "বৃষ্টি"}},
+		},
+		{"", "New", []DiffOp{{DiffInsert, "New"}}},
+		{"Same", "Same", []DiffOp{{DiffEqual, "Same"}}},
+	}
+
+	for _, tc := range testCases {
+		if got := DiffWords(tc.old, tc.new); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("DiffWords(%q, %q) = %v; want %v", tc.old, tc.new, got, tc.expected)
+		}
+	}
+}
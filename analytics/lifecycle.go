@@ -0,0 +1,238 @@
+package analytics
+
+import (
+	"sort"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// Lifecycle event types
+const (
+	EventAppear    = "appear"
+	EventRank      = "rank"
+	EventDisappear = "disappear"
+)
+
+// LifecycleEvent is a story entering or leaving a front page, or moving on it
+type LifecycleEvent struct {
+	At   time.Time `json:"at"`
+	Type string    `json:"type"`
+	// Rank is the rank after the event, zero when the story disappeared
+	Rank         int `json:"rank,omitempty"`
+	PreviousRank int `json:"previousRank,omitempty"`
+}
+
+// Lifecycle is the time a story spent on a source's front page
+type Lifecycle struct {
+	ID       string `json:"id"`
+	Source   string `json:"source"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	BestRank int    `json:"bestRank"`
+	// Entered is when the story first appeared on the page
+	Entered time.Time `json:"entered"`
+	// Left is when the story last disappeared from the page, nil while it is still on it
+	Left *time.Time `json:"left"`
+	// DwellSeconds is the total time the story was seen on the page, counting every time it
+	// reappeared. Each stay ends when the story was last seen, so that the time a source was
+	// failing and not observed is not counted.
+	DwellSeconds int64            `json:"dwellSeconds"`
+	Events       []LifecycleEvent `json:"events"`
+
+	stints []stint
+}
+
+// stint is an uninterrupted period a story spent on the page, ending when it was last seen on it
+type stint struct {
+	start, end time.Time
+	ongoing    bool
+	// truncated stints were already on the page when the source was first observed, so their
+	// real start is unknown
+	truncated bool
+}
+
+// Lifecycles replays the observations of the front pages, which must be in chronological order,
+// into the lifecycle of every story seen on them. Stories still on a page are counted until the
+// page was last seen. The result is ordered by when the stories entered, newest first.
+func Lifecycles(observations []history.Observation) []Lifecycle {
+	type state struct {
+		lifecycle *Lifecycle
+		rank      int
+		onPage    bool
+	}
+	var lifecycles []*Lifecycle
+	sources := make(map[string]map[string]*state)
+	// lastSeen holds when the latest page of each source was last seen
+	lastSeen := make(map[string]time.Time)
+
+	for _, o := range observations {
+		tracked, observed := sources[o.Source]
+		if !observed {
+			tracked = make(map[string]*state)
+			sources[o.Source] = tracked
+		}
+
+		present := make(map[string]bool, len(o.Items))
+		for i, item := range o.Items {
+			// Stories only found on a section page are not on the front page
+			if item.Prominence == headline.ProminenceSection {
+				continue
+			}
+			id := history.ArticleID(item.URL)
+			// A story linked twice on a page is placed where it appears first
+			if present[id] {
+				continue
+			}
+			present[id] = true
+			rank := item.Rank
+			if rank == 0 {
+				rank = i + 1
+			}
+
+			st, ok := tracked[id]
+			if !ok {
+				l := &Lifecycle{ID: id, Source: o.Source, URL: item.URL, Entered: o.At, BestRank: rank}
+				lifecycles = append(lifecycles, l)
+				st = &state{lifecycle: l}
+				tracked[id] = st
+			}
+			l := st.lifecycle
+			l.Title = item.Title
+			if rank < l.BestRank {
+				l.BestRank = rank
+			}
+
+			switch {
+			case !st.onPage:
+				l.Events = append(l.Events, LifecycleEvent{At: o.At, Type: EventAppear, Rank: rank})
+				l.stints = append(l.stints, stint{start: o.At, ongoing: true, truncated: !observed})
+				l.Left = nil
+			case rank != st.rank:
+				l.Events = append(l.Events, LifecycleEvent{At: o.At, Type: EventRank, Rank: rank, PreviousRank: st.rank})
+			}
+			st.rank = rank
+			st.onPage = true
+		}
+
+		for id, st := range tracked {
+			if !st.onPage || present[id] {
+				continue
+			}
+			l := st.lifecycle
+			l.Events = append(l.Events, LifecycleEvent{At: o.At, Type: EventDisappear, PreviousRank: st.rank})
+			last := &l.stints[len(l.stints)-1]
+			last.end, last.ongoing = lastSeen[o.Source], false
+			left := o.At
+			l.Left = &left
+			st.onPage = false
+			st.rank = 0
+		}
+		lastSeen[o.Source] = o.LastSeen()
+	}
+
+	result := make([]Lifecycle, 0, len(lifecycles))
+	for _, l := range lifecycles {
+		var dwell time.Duration
+		for i := range l.stints {
+			if l.stints[i].ongoing {
+				l.stints[i].end = lastSeen[l.Source]
+			}
+			dwell += l.stints[i].end.Sub(l.stints[i].start)
+		}
+		l.DwellSeconds = int64(dwell / time.Second)
+		result = append(result, *l)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Entered.Equal(result[j].Entered) {
+			return result[i].Entered.After(result[j].Entered)
+		}
+		if result[i].Source != result[j].Source {
+			return result[i].Source < result[j].Source
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// OnPageBetween reports whether the story was on the page at any time between from and to
+func (l Lifecycle) OnPageBetween(from, to time.Time) bool {
+	for _, s := range l.stints {
+		if !s.end.Before(from) && !s.start.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
+// DwellReport summarizes how long stories stay on a source's front page
+type DwellReport struct {
+	Source string    `json:"source"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	// Stories is the number of stories on the page at any time in the period
+	Stories int `json:"stories"`
+	// Entered and Left are the number of times a story appeared on or disappeared from the page
+	Entered int `json:"entered"`
+	Left    int `json:"left"`
+	// MedianDwellSeconds is the median time on the page of the stories that left in the period
+	MedianDwellSeconds int64 `json:"medianDwellSeconds"`
+	// TurnoverPerHour is the number of stories entering the page per hour
+	TurnoverPerHour float64 `json:"turnoverPerHour"`
+}
+
+// Dwell reports the dwell time and turnover of each source's front page between from and to,
+// ordered by source. Stories already on the page when a source was first observed are left out
+// of the median and the turnover since their real dwell time is unknown.
+func Dwell(lifecycles []Lifecycle, from, to time.Time) []DwellReport {
+	reports := make(map[string]*DwellReport)
+	dwells := make(map[string][]time.Duration)
+	for _, l := range lifecycles {
+		if !l.OnPageBetween(from, to) {
+			continue
+		}
+		report, ok := reports[l.Source]
+		if !ok {
+			report = &DwellReport{Source: l.Source, From: from, To: to}
+			reports[l.Source] = report
+		}
+		report.Stories++
+
+		for _, s := range l.stints {
+			if !s.truncated && !s.start.Before(from) && !s.start.After(to) {
+				report.Entered++
+			}
+			if s.ongoing || s.end.Before(from) || s.end.After(to) {
+				continue
+			}
+			report.Left++
+			if !s.truncated {
+				dwells[l.Source] = append(dwells[l.Source], s.end.Sub(s.start))
+			}
+		}
+	}
+
+	result := make([]DwellReport, 0, len(reports))
+	for source, report := range reports {
+		report.MedianDwellSeconds = int64(median(dwells[source]) / time.Second)
+		if hours := to.Sub(from).Hours(); hours > 0 {
+			report.TurnoverPerHour = float64(report.Entered) / hours
+		}
+		result = append(result, *report)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Source < result[j].Source })
+	return result
+}
+
+func median(durations []time.Duration) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	n := len(durations)
+	if n%2 == 1 {
+		return durations[n/2]
+	}
+	return (durations[n/2-1] + durations[n/2]) / 2
+}
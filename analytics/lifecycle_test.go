@@ -0,0 +1,96 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+func TestLifecycles(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store, _ := history.Open("", 0)
+	record := func(source string, hours int, titles ...string) {
+		resp := headline.Response{Source: headline.SourceInfo{ID: source}}
+		for i, title := range titles {
+			resp.Headlines = append(resp.Headlines, headline.NewsItem{Title: title, URL: "http://" + source + ".com/" + title, Rank: i + 1})
+		}
+		store.Record(start.Add(time.Duration(hours)*time.Hour), []headline.Response{resp})
+	}
+	record("a", 0, "old", "other")
+	record("b", 0, "b1")
+	record("a", 1, "new", "old", "other")
+	record("b", 2, "b1")
+	record("a", 3, "new", "other")
+	record("a", 5, "other", "new")
+	record("a", 6, "old", "other")
+	// The page of a is seen unchanged while b is failing
+	record("a", 8, "old", "other")
+	now := start.Add(8 * time.Hour)
+
+	lifecycles := Lifecycles(store.Observations("", time.Time{}, time.Time{}))
+	byTitle := make(map[string]Lifecycle)
+	for _, l := range lifecycles {
+		byTitle[l.Source+"/"+l.Title] = l
+	}
+	if len(lifecycles) != 4 || lifecycles[0].Title != "new" {
+		t.Fatalf("Expected 4 lifecycles, newest first, got %+v", lifecycles)
+	}
+
+	story := byTitle["a/new"]
+	expected := []LifecycleEvent{
+		{At: start.Add(time.Hour), Type: EventAppear, Rank: 1},
+		{At: start.Add(5 * time.Hour), Type: EventRank, Rank: 2, PreviousRank: 1},
+		{At: start.Add(6 * time.Hour), Type: EventDisappear, PreviousRank: 2},
+	}
+	if !reflect.DeepEqual(story.Events, expected) {
+		t.Errorf("Events = %+v; want %+v", story.Events, expected)
+	}
+	// The story was last seen at 5h
+	if story.Left == nil || !story.Left.Equal(start.Add(6*time.Hour)) || story.DwellSeconds != 4*3600 {
+		t.Errorf("Expected the story to leave after 4h, got %+v", story)
+	}
+
+	// A story that returns to the page accumulates its dwell time
+	old := byTitle["a/old"]
+	if old.Left != nil || old.DwellSeconds != (1+2)*3600 || len(old.Events) != 4 {
+		t.Errorf("Expected the returning story to be on the page for 3h, got %+v", old)
+	}
+	if other := byTitle["a/other"]; other.BestRank != 1 || other.DwellSeconds != 8*3600 {
+		t.Errorf("Expected the other story to be on the page all along, got %+v", other)
+	}
+	// The story of the failing source is only counted until its page was last seen
+	if b1 := byTitle["b/b1"]; b1.Left != nil || b1.DwellSeconds != 2*3600 {
+		t.Errorf("Expected the story of the failing source to be on the page for 2h, got %+v", b1)
+	}
+
+	reports := Dwell(lifecycles, start, now)
+	if len(reports) != 2 || reports[0].Source != "a" || reports[1].Source != "b" {
+		t.Fatalf("Expected a report per source, got %+v", reports)
+	}
+	// The stories on the page at the first observation do not count towards turnover and median
+	a := reports[0]
+	if a.Stories != 3 || a.Entered != 2 || a.Left != 2 || a.MedianDwellSeconds != 4*3600 || a.TurnoverPerHour != 0.25 {
+		t.Errorf("Unexpected report %+v", a)
+	}
+
+	if reports := Dwell(lifecycles, start.Add(7*time.Hour), now); len(reports) != 1 || reports[0].Stories != 2 {
+		t.Errorf("Expected only the stories on the page in the period, got %+v", reports)
+	}
+}
+
+func TestLifecyclesSkipSections(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store, _ := history.Open("", 0)
+	store.Record(start, []headline.Response{{Source: headline.SourceInfo{ID: "a"}, Headlines: []headline.NewsItem{
+		{Title: "lead", URL: "http://a.com/lead", Rank: 1, Prominence: headline.ProminenceLead},
+		{Title: "section", URL: "http://a.com/section", Prominence: headline.ProminenceSection},
+	}}})
+
+	lifecycles := Lifecycles(store.Observations("", time.Time{}, time.Time{}))
+	if len(lifecycles) != 1 || lifecycles[0].Title != "lead" {
+		t.Errorf("Expected only the story on the front page, got %+v", lifecycles)
+	}
+}
@@ -0,0 +1,64 @@
+// Package analytics computes reports over the headlines stored in the history
+package analytics
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// stopwords are frequent English and Bengali words that carry no topic
+var stopwords = make(map[string]bool)
+
+func init() {
+	for _, word := range strings.Fields(`
+		a an and are as at be by for from has have in is it its of on or that the this to was were will with
+		after over says said new how why what who not no than more into about up out
+		ও এবং বা এ এই সেই যে যা করে করা করতে হয় হবে হচ্ছে হয়েছে ছিল থেকে জন্য না নয় কি কী
+		তার তাদের তিনি আর এক একটি দিয়ে নিয়ে সঙ্গে সাথে পর বলে বললেন মধ্যে কাছে প্রতি আরও শুরু
+	`) {
+		stopwords[norm.NFC.String(word)] = true
+	}
+}
+
+// Tokenize splits a headline into lowercase words, dropping punctuation, stopwords and single
+// characters. Bengali vowel signs and joiners are kept as part of the words.
+func Tokenize(text string) []string {
+	text = norm.NFC.String(strings.ToLower(text))
+
+	var tokens []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && r != '\u200c' && r != '\u200d'
+	}) {
+		word = strings.Trim(word, "\u200c\u200d")
+		if utf8.RuneCountInString(word) < 2 || stopwords[word] {
+			continue
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
+
+// similarity returns the Jaccard similarity of two token sets
+func similarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for token := range a {
+		if b[token] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+func tokenSet(text string) map[string]bool {
+	set := make(map[string]bool)
+	for _, token := range Tokenize(text) {
+		set[token] = true
+	}
+	return set
+}
@@ -0,0 +1,111 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// TopOptions configures the ranking of top stories
+type TopOptions struct {
+	// Limit is the maximum number of stories returned, 0 for all
+	Limit int
+	// HalfLife is the age at which the recency weight of a story halves
+	HalfLife time.Duration
+	// Similarity is the minimum Jaccard similarity of the words of two headlines from different
+	// sources to count them as coverage of the same story
+	Similarity float64
+}
+
+// DefaultTopOptions are used for the options that are not set
+var DefaultTopOptions = TopOptions{
+	Limit:      20,
+	HalfLife:   6 * time.Hour,
+	Similarity: 0.4,
+}
+
+// TopStory is a story ranked across sources
+type TopStory struct {
+	history.Article
+	Score float64 `json:"score"`
+	// Coverage are the articles of other sources covering the same story
+	Coverage []history.Article `json:"coverage,omitempty"`
+}
+
+// Top ranks the articles into a single list of top stories. Each article is scored by its best
+// prominence and rank on its front page and by its recency; articles never on a front page are
+// left out. Articles of different sources with similar headlines are merged into one story, whose
+// score grows with the number of sources covering it.
+func Top(articles []history.Article, now time.Time, opts TopOptions) []TopStory {
+	if opts.HalfLife <= 0 {
+		opts.HalfLife = DefaultTopOptions.HalfLife
+	}
+	if opts.Similarity <= 0 {
+		opts.Similarity = DefaultTopOptions.Similarity
+	}
+
+	type candidate struct {
+		article history.Article
+		score   float64
+		tokens  map[string]bool
+	}
+	candidates := make([]candidate, 0, len(articles))
+	for _, a := range articles {
+		// Stories only ever found on a section page were never on the front page
+		if a.BestProminence == headline.ProminenceSection {
+			continue
+		}
+		candidates = append(candidates, candidate{article: a, score: articleScore(a, now, opts.HalfLife), tokens: tokenSet(a.Title)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	// Every article joins the best scoring story it is similar to, so a story is led by its
+	// most prominent article
+	var stories []TopStory
+	var storyTokens []map[string]bool
+	var storySources []map[string]bool
+	for _, c := range candidates {
+		joined := false
+		for i := range stories {
+			if storySources[i][c.article.Source] || similarity(storyTokens[i], c.tokens) < opts.Similarity {
+				continue
+			}
+			stories[i].Coverage = append(stories[i].Coverage, c.article)
+			storySources[i][c.article.Source] = true
+			joined = true
+			break
+		}
+		if !joined {
+			stories = append(stories, TopStory{Article: c.article, Score: c.score})
+			storyTokens = append(storyTokens, c.tokens)
+			storySources = append(storySources, map[string]bool{c.article.Source: true})
+		}
+	}
+
+	for i := range stories {
+		stories[i].Score *= float64(len(storySources[i]))
+		stories[i].Score = math.Round(stories[i].Score*1000) / 1000
+	}
+	sort.SliceStable(stories, func(i, j int) bool { return stories[i].Score > stories[j].Score })
+
+	if opts.Limit > 0 && len(stories) > opts.Limit {
+		stories = stories[:opts.Limit]
+	}
+	return stories
+}
+
+// articleScore weighs an article by its prominence, its rank and the time since it first appeared
+func articleScore(a history.Article, now time.Time, halfLife time.Duration) float64 {
+	score := headline.ProminenceWeight(a.BestProminence)
+	if a.BestRank > 0 {
+		score /= math.Sqrt(float64(a.BestRank))
+	}
+	age := now.Sub(a.FirstSeen)
+	if age > 0 {
+		score *= math.Pow(0.5, float64(age)/float64(halfLife))
+	}
+	return score
+}
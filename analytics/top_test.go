@@ -0,0 +1,64 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+func TestTokenize(t *testing.T) {
+	testCases := []struct {
+		text     string
+		expected []string
+	}{
+		{"The Election Results are in!", []string{"election", "results"}},
+		{"ঢাকায় বৃষ্টি, জনজীবন বিপর্যস্ত।", []string{"ঢাকায়", "বৃষ্টি", "জনজীবন", "বিপর্যস্ত"}},
+		{"নির্বাচন ও ভোট", []string{"নির্বাচন", "ভোট"}},
+	}
+
+	for _, tc := range testCases {
+		if got := Tokenize(tc.text); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Tokenize(%q) = %q; want %q", tc.text, got, tc.expected)
+		}
+	}
+}
+
+func TestTop(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	articles := []history.Article{
+		{ID: "1", Source: "a", Title: "বাজেট ঘোষণা করলেন অর্থমন্ত্রী", BestRank: 2, BestProminence: headline.ProminenceTop, FirstSeen: now.Add(-time.Hour)},
+		{ID: "2", Source: "b", Title: "অর্থমন্ত্রী বাজেট ঘোষণা করলেন সংসদে", BestRank: 5, BestProminence: headline.ProminenceRegular, FirstSeen: now.Add(-time.Hour)},
+		{ID: "3", Source: "a", Title: "Cricket team wins series", BestRank: 1, BestProminence: headline.ProminenceLead, FirstSeen: now.Add(-time.Hour)},
+		{ID: "4", Source: "b", Title: "Old lead story", BestRank: 1, BestProminence: headline.ProminenceLead, FirstSeen: now.Add(-24 * time.Hour)},
+	}
+
+	stories := Top(articles, now, TopOptions{Limit: 3})
+	if len(stories) != 3 {
+		t.Fatalf("Expected 3 stories, got %d", len(stories))
+	}
+
+	// The lead story ranks first, the budget story covered by both sources second
+	// and the old lead story last
+	ids := []string{stories[0].ID, stories[1].ID, stories[2].ID}
+	if !reflect.DeepEqual(ids, []string{"3", "1", "4"}) {
+		t.Errorf("Expected stories 3, 1 and 4, got %v", ids)
+	}
+	if len(stories[1].Coverage) != 1 || stories[1].Coverage[0].ID != "2" {
+		t.Errorf("Expected article 2 as coverage of the budget story, got %+v", stories[1].Coverage)
+	}
+}
+
+func TestTopSkipsSections(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	articles := []history.Article{
+		{ID: "1", Source: "a", Title: "Cricket team wins series", BestRank: 1, BestProminence: headline.ProminenceLead, FirstSeen: now},
+		{ID: "2", Source: "a", Title: "Football league starts", BestProminence: headline.ProminenceSection, FirstSeen: now},
+	}
+
+	if stories := Top(articles, now, TopOptions{}); len(stories) != 1 || stories[0].ID != "1" {
+		t.Errorf("Expected only the story on the front page, got %+v", stories)
+	}
+}
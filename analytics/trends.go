@@ -0,0 +1,230 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// TrendOptions configures the detection of trending terms
+type TrendOptions struct {
+	// Window is the period whose terms are reported
+	Window time.Duration
+	// Baseline is the period before the window the term frequencies are compared to
+	Baseline time.Duration
+	// MinCount is the minimum number of headlines in the window a term must appear in
+	MinCount int
+	// Limit is the maximum number of terms returned, 0 for all
+	Limit int
+	// Headlines is the maximum number of supporting headlines per term
+	Headlines int
+	// Source only considers the headlines of this source when set
+	Source string
+	// HistoryStart is when the articles start being complete, see history.Store.Start. A
+	// baseline starting earlier is shortened to it, since the headlines missing from it would
+	// make every term look like it is rising.
+	HistoryStart time.Time
+}
+
+// DefaultTrendOptions are used for the options that are not set
+var DefaultTrendOptions = TrendOptions{
+	Window:    24 * time.Hour,
+	Baseline:  7 * 24 * time.Hour,
+	MinCount:  2,
+	Limit:     20,
+	Headlines: 5,
+}
+
+// TrendReport lists the terms rising within a window
+type TrendReport struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// BaselineFrom is when the baseline starts, later than requested when the history is shorter
+	BaselineFrom time.Time `json:"baselineFrom"`
+	// Headlines is the number of headlines first seen in the window
+	Headlines int     `json:"headlines"`
+	Terms     []Trend `json:"terms"`
+}
+
+// Trend is a term or bigram and how much more often it appears than in the baseline
+type Trend struct {
+	Term string `json:"term"`
+	// Count is the number of headlines in the window containing the term
+	Count int `json:"count"`
+	// BaselineCount is the number of headlines in the baseline containing the term
+	BaselineCount int `json:"baselineCount"`
+	// Expected is the count expected in the window at the baseline rate
+	Expected float64 `json:"expected"`
+	// Score is how far the count exceeds the expected count, in standard deviations of a Poisson distribution
+	Score float64 `json:"score"`
+	// Sources is the count per source
+	Sources   map[string]int  `json:"sources"`
+	Headlines []TrendHeadline `json:"headlines"`
+}
+
+// TrendHeadline is a headline containing a trending term
+type TrendHeadline struct {
+	Source    string    `json:"source"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	FirstSeen time.Time `json:"firstSeen"`
+}
+
+// Terms returns the words and bigrams of a headline, each once
+func Terms(title string) []string {
+	tokens := Tokenize(title)
+	seen := make(map[string]bool, 2*len(tokens))
+	var terms []string
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for i, token := range tokens {
+		add(token)
+		if i > 0 {
+			add(tokens[i-1] + " " + token)
+		}
+	}
+	return terms
+}
+
+// Trends finds the terms of the headlines first seen within the window ending at now that appear
+// more often than in the baseline period before it. Each headline counts once per term.
+func Trends(articles []history.Article, now time.Time, opts TrendOptions) TrendReport {
+	if opts.Window <= 0 {
+		opts.Window = DefaultTrendOptions.Window
+	}
+	if opts.Baseline <= 0 {
+		opts.Baseline = DefaultTrendOptions.Baseline
+	}
+	if opts.MinCount <= 0 {
+		opts.MinCount = DefaultTrendOptions.MinCount
+	}
+	if opts.Headlines <= 0 {
+		opts.Headlines = DefaultTrendOptions.Headlines
+	}
+
+	from := now.Add(-opts.Window)
+	baselineFrom := from.Add(-opts.Baseline)
+	if opts.HistoryStart.After(baselineFrom) {
+		baselineFrom = opts.HistoryStart
+		if baselineFrom.After(from) {
+			baselineFrom = from
+		}
+	}
+	report := TrendReport{From: from, To: now, BaselineFrom: baselineFrom, Terms: []Trend{}}
+
+	current := make(map[string]*Trend)
+	baseline := make(map[string]int)
+	var window []history.Article
+	for _, a := range articles {
+		if (opts.Source != "" && a.Source != opts.Source) || a.FirstSeen.Before(baselineFrom) || a.FirstSeen.After(now) {
+			continue
+		}
+		if a.FirstSeen.Before(from) {
+			for _, term := range Terms(a.Title) {
+				baseline[term]++
+			}
+			continue
+		}
+
+		window = append(window, a)
+		for _, term := range Terms(a.Title) {
+			t, ok := current[term]
+			if !ok {
+				t = &Trend{Term: term, Sources: make(map[string]int)}
+				current[term] = t
+			}
+			t.Count++
+			t.Sources[a.Source]++
+		}
+	}
+	report.Headlines = len(window)
+
+	// Newest headlines support a term first
+	sort.SliceStable(window, func(i, j int) bool { return window[i].FirstSeen.After(window[j].FirstSeen) })
+
+	// Without a baseline every term is expected not to appear
+	var scale float64
+	if baseline := from.Sub(baselineFrom); baseline > 0 {
+		scale = float64(opts.Window) / float64(baseline)
+	}
+	for term, t := range current {
+		if t.Count < opts.MinCount {
+			continue
+		}
+		t.BaselineCount = baseline[term]
+		t.Expected = float64(t.BaselineCount) * scale
+		t.Score = (float64(t.Count) - t.Expected) / math.Sqrt(t.Expected+1)
+		if t.Score <= 0 {
+			continue
+		}
+		t.Expected = math.Round(t.Expected*100) / 100
+		t.Score = math.Round(t.Score*100) / 100
+		report.Terms = append(report.Terms, *t)
+	}
+
+	sort.Slice(report.Terms, func(i, j int) bool {
+		a, b := report.Terms[i], report.Terms[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		// Bigrams come first so that the words they cover can be dropped
+		if wa, wb := strings.Count(a.Term, " "), strings.Count(b.Term, " "); wa != wb {
+			return wa > wb
+		}
+		return a.Term < b.Term
+	})
+	report.Terms = dropCoveredTerms(report.Terms)
+	if opts.Limit > 0 && len(report.Terms) > opts.Limit {
+		report.Terms = report.Terms[:opts.Limit]
+	}
+
+	for i := range report.Terms {
+		report.Terms[i].Headlines = supportingHeadlines(window, report.Terms[i].Term, opts.Headlines)
+	}
+	return report
+}
+
+// dropCoveredTerms removes the words that only rise as part of a bigram ranked above them,
+// so that "padma bridge" is not followed by "padma" and "bridge" with the same headlines
+func dropCoveredTerms(terms []Trend) []Trend {
+	kept := []Trend{}
+	for _, t := range terms {
+		covered := false
+		if !strings.Contains(t.Term, " ") {
+			for _, k := range kept {
+				if k.Count == t.Count && strings.Contains(" "+k.Term+" ", " "+t.Term+" ") {
+					covered = true
+					break
+				}
+			}
+		}
+		if !covered {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func supportingHeadlines(articles []history.Article, term string, limit int) []TrendHeadline {
+	var headlines []TrendHeadline
+	for _, a := range articles {
+		for _, t := range Terms(a.Title) {
+			if t != term {
+				continue
+			}
+			headlines = append(headlines, TrendHeadline{Source: a.Source, Title: a.Title, URL: a.URL, FirstSeen: a.FirstSeen})
+			break
+		}
+		if len(headlines) == limit {
+			break
+		}
+	}
+	return headlines
+}
@@ -0,0 +1,87 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/history"
+)
+
+func TestTerms(t *testing.T) {
+	expected := []string{"পদ্মা", "সেতু", "পদ্মা সেতু", "উদ্বোধন", "সেতু উদ্বোধন", "উদ্বোধন পদ্মা"}
+	if got := Terms("পদ্মা সেতু উদ্বোধন, পদ্মা"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Terms() = %q; want %q", got, expected)
+	}
+}
+
+func TestTrends(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	article := func(source, title string, age time.Duration) history.Article {
+		return history.Article{Source: source, Title: title, URL: "http://test.com/" + title, FirstSeen: now.Add(-age)}
+	}
+	articles := []history.Article{
+		// Baseline: the economy is always covered
+		article("a", "Economy grows", 48*time.Hour),
+		article("b", "Economy slows", 72*time.Hour),
+		article("a", "Economy outlook", 96*time.Hour),
+		// Window
+		article("a", "Padma bridge opens", time.Hour),
+		article("b", "Crowds at Padma bridge", 2*time.Hour),
+		article("a", "Padma bridge toll announced", 3*time.Hour),
+		article("b", "Economy steady", 4*time.Hour),
+		// Outside the baseline
+		article("a", "Economy economy", 30*24*time.Hour),
+	}
+
+	report := Trends(articles, now, TrendOptions{})
+	if report.Headlines != 4 {
+		t.Errorf("Expected 4 headlines in the window, got %d", report.Headlines)
+	}
+	if len(report.Terms) != 1 {
+		t.Fatalf("Expected only the bridge to trend, got %+v", report.Terms)
+	}
+
+	trend := report.Terms[0]
+	if trend.Term != "padma bridge" || trend.Count != 3 || trend.BaselineCount != 0 {
+		t.Errorf("Expected padma bridge in 3 headlines, got %+v", trend)
+	}
+	if !reflect.DeepEqual(trend.Sources, map[string]int{"a": 2, "b": 1}) {
+		t.Errorf("Expected counts per source, got %v", trend.Sources)
+	}
+	if len(trend.Headlines) != 3 || trend.Headlines[0].Title != "Padma bridge opens" {
+		t.Errorf("Expected the newest supporting headline first, got %+v", trend.Headlines)
+	}
+
+	if report := Trends(articles, now, TrendOptions{Source: "b"}); len(report.Terms) != 0 {
+		t.Errorf("Expected no trends for a single mention, got %+v", report.Terms)
+	}
+}
+
+func TestTrendsShortHistory(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	article := func(title string, age time.Duration) history.Article {
+		return history.Article{Source: "a", Title: title, URL: "http://test.com/" + title, FirstSeen: now.Add(-age)}
+	}
+	articles := []history.Article{
+		article("Economy grows", 36*time.Hour),
+		article("Economy steady", time.Hour),
+		article("Economy slows", 2*time.Hour),
+	}
+
+	// The baseline of a history of two days is the day before the window, not the week
+	historyStart := now.Add(-48 * time.Hour)
+	report := Trends(articles, now, TrendOptions{HistoryStart: historyStart})
+	if !report.BaselineFrom.Equal(historyStart) {
+		t.Errorf("Expected the baseline to start with the history at %v, got %v", historyStart, report.BaselineFrom)
+	}
+	if len(report.Terms) != 1 || report.Terms[0].Expected != 1 {
+		t.Errorf("Expected economy once a day, got %+v", report.Terms)
+	}
+
+	// A history starting within the window leaves no baseline
+	report = Trends(articles, now, TrendOptions{HistoryStart: now.Add(-2 * time.Hour)})
+	if !report.BaselineFrom.Equal(report.From) || len(report.Terms) != 1 || report.Terms[0].Expected != 0 {
+		t.Errorf("Expected no baseline, got %+v", report)
+	}
+}
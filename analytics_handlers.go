@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shaharia-lab/headlines/analytics"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// durationParam parses an optional duration query parameter
+func durationParam(r *http.Request, name string, fallback time.Duration) (time.Duration, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a positive duration such as 24h", name, value)
+	}
+	return d, nil
+}
+
+// intParam parses an optional positive integer query parameter
+func intParam(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a positive integer", name, value)
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// topStories ranks the stories of all sources seen within the window of the request
+func topStories(store *history.Store, r *http.Request) ([]analytics.TopStory, error) {
+	window, err := durationParam(r, "window", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := intParam(r, "limit", analytics.DefaultTopOptions.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	opts := analytics.DefaultTopOptions
+	opts.Limit = limit
+	stories := analytics.Top(store.Articles(now.Add(-window)), now, opts)
+	if stories == nil {
+		stories = []analytics.TopStory{}
+	}
+	return stories, nil
+}
+
+// topHandler serves the stories of all sources seen within the window as a single ranked list
+func topHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stories, err := topStories(store, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, stories)
+	}
+}
+
+// trends reports the terms rising in the headlines of the request's window
+func trends(store *history.Store, r *http.Request) (analytics.TrendReport, error) {
+	opts := analytics.DefaultTrendOptions
+	var err error
+	if opts.Window, err = durationParam(r, "window", opts.Window); err != nil {
+		return analytics.TrendReport{}, err
+	}
+	if opts.Baseline, err = durationParam(r, "baseline", opts.Baseline); err != nil {
+		return analytics.TrendReport{}, err
+	}
+	if opts.Limit, err = intParam(r, "limit", opts.Limit); err != nil {
+		return analytics.TrendReport{}, err
+	}
+	opts.Source = r.URL.Query().Get("source")
+	opts.HistoryStart = store.Start()
+
+	now := time.Now()
+	articles := store.Articles(now.Add(-opts.Window - opts.Baseline))
+	return analytics.Trends(articles, now, opts), nil
+}
+
+// trendsHandler serves the terms rising in the headlines of the window compared to the baseline before it
+func trendsHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, err := trends(store, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, report)
+	}
+}
+
+// revision is a title of an article with the word diff to the previous title
+type revision struct {
+	history.Revision
+	Diff []analytics.DiffOp `json:"diff,omitempty"`
+}
+
+// articleRevisions is an article with every distinct title it had
+type articleRevisions struct {
+	Article   history.Article `json:"article"`
+	Revisions []revision      `json:"revisions"`
+}
+
+// revisionsOf returns the titles of an article, oldest first
+func revisionsOf(store *history.Store, id string) (articleRevisions, bool) {
+	article, ok := store.Article(id)
+	revisions, _ := store.Revisions(id)
+	if !ok {
+		return articleRevisions{}, false
+	}
+
+	response := articleRevisions{Article: article, Revisions: make([]revision, len(revisions))}
+	for i, rev := range revisions {
+		response.Revisions[i].Revision = rev
+		if i > 0 {
+			response.Revisions[i].Diff = analytics.DiffWords(revisions[i-1].Title, rev.Title)
+		}
+	}
+	return response, true
+}
+
+// articleRevisionsHandler serves every distinct title an article had, oldest first
+func articleRevisionsHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, ok := revisionsOf(store, chi.URLParam(r, "id"))
+		if !ok {
+			http.Error(w, "Article not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, response)
+	}
+}
+
+// edit is a title change with its word diff
+type edit struct {
+	history.Edit
+	Diff []analytics.DiffOp `json:"diff"`
+}
+
+// editsSince returns the title changes of a source, or of all sources if source is empty, made
+// since the given time, newest first
+func editsSince(store *history.Store, since time.Time, source string) []history.Edit {
+	edits := []history.Edit{}
+	for _, e := range store.Edits(since) {
+		if source == "" || e.Article.Source == source {
+			edits = append(edits, e)
+		}
+	}
+	return edits
+}
+
+// withDiffs adds the word diff to the title changes. The diffs are only computed for the edits
+// that are served, as they are the costly part of the feed.
+func withDiffs(edits []history.Edit) []edit {
+	diffed := make([]edit, len(edits))
+	for i, e := range edits {
+		diffed[i] = edit{Edit: e, Diff: analytics.DiffWords(e.PreviousTitle, e.Title)}
+	}
+	return diffed
+}
+
+// editsHandler serves the feed of headlines edited within the window, newest first
+func editsHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := durationParam(r, "window", 24*time.Hour)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, err := intParam(r, "limit", 50)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		edits := editsSince(store, time.Now().Add(-window), r.URL.Query().Get("source"))
+		writeJSON(w, withDiffs(edits[:min(limit, len(edits))]))
+	}
+}
+
+// lifecycleReplay keeps the lifecycles replayed from the history until it records another
+// refresh, so that the requests in between do not replay the whole history again
+type lifecycleReplay struct {
+	store *history.Store
+
+	mu         sync.Mutex
+	version    uint64
+	lifecycles []analytics.Lifecycle
+}
+
+func newLifecycleReplay(store *history.Store) *lifecycleReplay {
+	return &lifecycleReplay{store: store}
+}
+
+// all returns the lifecycles of the stories of all sources, which must not be modified
+func (l *lifecycleReplay) all() []analytics.Lifecycle {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if version := l.store.Version(); l.lifecycles == nil || version != l.version {
+		// The whole history is replayed so that stories entering before a window keep their real start
+		l.lifecycles = analytics.Lifecycles(l.store.Observations("", time.Time{}, time.Time{}))
+		l.version = version
+	}
+	return l.lifecycles
+}
+
+// lifecyclesWithin returns the lifecycle of the stories of a source, or of all sources if source
+// is empty, that were on the front pages within the window, most recently entered first
+func lifecyclesWithin(replay *lifecycleReplay, window time.Duration, source string) []analytics.Lifecycle {
+	now := time.Now()
+	lifecycles := []analytics.Lifecycle{}
+	for _, l := range replay.all() {
+		if (source == "" || l.Source == source) && l.OnPageBetween(now.Add(-window), now) {
+			lifecycles = append(lifecycles, l)
+		}
+	}
+	return lifecycles
+}
+
+// lifecyclesHandler serves the lifecycle of the stories on the front pages within the window,
+// most recently entered first
+func lifecyclesHandler(replay *lifecycleReplay) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := durationParam(r, "window", 24*time.Hour)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		limit, err := intParam(r, "limit", 100)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lifecycles := lifecyclesWithin(replay, window, r.URL.Query().Get("source"))
+		writeJSON(w, lifecycles[:min(limit, len(lifecycles))])
+	}
+}
+
+// dwell reports the dwell time and turnover of each source's front page within the request's window
+func dwell(replay *lifecycleReplay, r *http.Request) ([]analytics.DwellReport, error) {
+	window, err := durationParam(r, "window", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	lifecycles := replay.all()
+	if source := r.URL.Query().Get("source"); source != "" {
+		var ofSource []analytics.Lifecycle
+		for _, l := range lifecycles {
+			if l.Source == source {
+				ofSource = append(ofSource, l)
+			}
+		}
+		lifecycles = ofSource
+	}
+	now := time.Now()
+	return analytics.Dwell(lifecycles, now.Add(-window), now), nil
+}
+
+// dwellHandler serves the dwell time and turnover of each source's front page within the window
+func dwellHandler(replay *lifecycleReplay) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reports, err := dwell(replay, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, reports)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shaharia-lab/headlines/analytics"
+	"github.com/shaharia-lab/headlines/archive"
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// Page sizes of the cursor paginated endpoints
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// envelope wraps every successful response of the versioned API
+type envelope struct {
+	Data  any   `json:"data"`
+	Meta  meta  `json:"meta"`
+	Links links `json:"links"`
+}
+
+type meta struct {
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Count is the number of items in data when it is a list
+	Count *int `json:"count,omitempty"`
+	// Cache tells whether the headlines were served from the cache, HIT or MISS
+	Cache      string `json:"cache,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type links struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+}
+
+// problem is an RFC 7807 problem details object, the body of every error of the versioned API
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   detail,
+		Instance: r.URL.Path,
+	})
+}
+
+// writeData writes data in an envelope, linking to the next page when meta has a cursor
+func writeData(w http.ResponseWriter, r *http.Request, data any, m meta) {
+	m.GeneratedAt = time.Now().UTC()
+	env := envelope{Data: data, Meta: m, Links: links{Self: r.URL.RequestURI()}}
+	if m.NextCursor != "" {
+		next := *r.URL
+		query := next.Query()
+		query.Set("cursor", m.NextCursor)
+		next.RawQuery = query.Encode()
+		env.Links.Next = next.RequestURI()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(env)
+}
+
+// writeList writes a list in an envelope with its count
+func writeList[T any](w http.ResponseWriter, r *http.Request, items []T, m meta) {
+	if items == nil {
+		items = []T{}
+	}
+	count := len(items)
+	m.Count = &count
+	writeData(w, r, items, m)
+}
+
+// cursor is the position of an item in a list sorted newest first, with the key breaking ties.
+// Clients receive it encoded and pass it back as is.
+type cursor struct {
+	At  time.Time `json:"at"`
+	Key string    `json:"key"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(value string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &c)
+	}
+	if err != nil || c.At.IsZero() {
+		return cursor{}, errors.New("invalid cursor, pass the nextCursor of a previous page as is")
+	}
+	return c, nil
+}
+
+// before returns whether c comes before other in a list sorted newest first
+func (c cursor) before(other cursor) bool {
+	if !c.At.Equal(other.At) {
+		return c.At.After(other.At)
+	}
+	return c.Key < other.Key
+}
+
+// paginate returns the page of items after the cursor of the request and the cursor of the next
+// page, empty on the last page. The items must be sorted in the order of their position. Since
+// the cursor is a position rather than an offset, items added to the list between requests do
+// not shift the following pages.
+func paginate[T any](r *http.Request, items []T, position func(T) cursor) ([]T, string, error) {
+	limit, err := intParam(r, "limit", defaultPageSize)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit > maxPageSize {
+		return nil, "", fmt.Errorf("invalid limit %d, expected at most %d", limit, maxPageSize)
+	}
+
+	start := 0
+	if value := r.URL.Query().Get("cursor"); value != "" {
+		after, err := decodeCursor(value)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(items), func(i int) bool { return after.before(position(items[i])) })
+	}
+
+	end := min(start+limit, len(items))
+	var next string
+	if end < len(items) {
+		next = position(items[end-1]).encode()
+	}
+	return items[start:end], next, nil
+}
+
+// apiV1Routes serves the versioned API. Responses are wrapped in an envelope, lists of the
+// history are paginated with cursors and errors are problem details.
+func apiV1Routes(aggregator *headline.Aggregator, store *history.Store, replay *lifecycleReplay, pages *archive.Archive) func(chi.Router) {
+	sources := aggregator.Sources
+	return func(r chi.Router) {
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			writeProblem(w, r, http.StatusNotFound, "")
+		})
+		r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+			writeProblem(w, r, http.StatusMethodNotAllowed, "")
+		})
+
+		r.Get("/headlines", v1HeadlinesHandler(aggregator))
+		r.Get("/sources", func(w http.ResponseWriter, r *http.Request) {
+			writeList(w, r, sourceStatuses(sources), meta{})
+		})
+		r.Get("/top", func(w http.ResponseWriter, r *http.Request) {
+			stories, err := topStories(store, r)
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeList(w, r, stories, meta{})
+		})
+		r.Get("/trends", func(w http.ResponseWriter, r *http.Request) {
+			report, err := trends(store, r)
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeData(w, r, report, meta{})
+		})
+		r.Get("/articles", v1ArticlesHandler(store))
+		r.Get("/articles/{id}/revisions", func(w http.ResponseWriter, r *http.Request) {
+			response, ok := revisionsOf(store, chi.URLParam(r, "id"))
+			if !ok {
+				writeProblem(w, r, http.StatusNotFound, "Article not found")
+				return
+			}
+			writeData(w, r, response, meta{})
+		})
+		r.Get("/edits", v1EditsHandler(store))
+		r.Get("/lifecycles", v1LifecyclesHandler(replay))
+		r.Get("/dwell", func(w http.ResponseWriter, r *http.Request) {
+			reports, err := dwell(replay, r)
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeList(w, r, reports, meta{})
+		})
+		r.Get("/snapshots", func(w http.ResponseWriter, r *http.Request) {
+			responses, err := snapshots(pages, sources, r)
+			if errors.Is(err, errArchiveDisabled) {
+				writeProblem(w, r, http.StatusNotFound, err.Error())
+				return
+			}
+			if err != nil {
+				writeProblem(w, r, http.StatusBadRequest, err.Error())
+				return
+			}
+			writeList(w, r, responses, meta{})
+		})
+	}
+}
+
+func v1HeadlinesHandler(aggregator *headline.Aggregator) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		category, err := categoryParam(r)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		cached, isCached := aggregator.Headlines()
+		headlines := cached.Body
+		if category != "" {
+			headlines = headline.FilterCategory(headlines, category)
+		}
+		m := meta{Cache: "MISS"}
+		if isCached {
+			m.Cache = "HIT"
+		}
+		writeList(w, r, headlines, m)
+	}
+}
+
+// v1ArticlesHandler serves the articles seen within the window, most recently first seen first,
+// optionally those of a source or with the q parameter in their title. The articles are not
+// ordered by when they were last seen, which changes on every refresh and would move them
+// across the pages of the cursor.
+func v1ArticlesHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := durationParam(r, "window", 24*time.Hour)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		source := r.URL.Query().Get("source")
+		q := strings.ToLower(r.URL.Query().Get("q"))
+
+		articles := []history.Article{}
+		for _, a := range store.Articles(time.Now().Add(-window)) {
+			if (source != "" && a.Source != source) || !strings.Contains(strings.ToLower(a.Title), q) {
+				continue
+			}
+			articles = append(articles, a)
+		}
+		sort.Slice(articles, func(i, j int) bool {
+			if !articles[i].FirstSeen.Equal(articles[j].FirstSeen) {
+				return articles[i].FirstSeen.After(articles[j].FirstSeen)
+			}
+			return articles[i].ID < articles[j].ID
+		})
+
+		page, next, err := paginate(r, articles, func(a history.Article) cursor {
+			return cursor{At: a.FirstSeen, Key: a.ID}
+		})
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeList(w, r, page, meta{NextCursor: next})
+	}
+}
+
+func v1EditsHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := durationParam(r, "window", 24*time.Hour)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		edits := editsSince(store, time.Now().Add(-window), r.URL.Query().Get("source"))
+		page, next, err := paginate(r, edits, func(e history.Edit) cursor {
+			return cursor{At: e.EditedAt, Key: e.Article.ID}
+		})
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeList(w, r, withDiffs(page), meta{NextCursor: next})
+	}
+}
+
+func v1LifecyclesHandler(replay *lifecycleReplay) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		window, err := durationParam(r, "window", 24*time.Hour)
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		lifecycles := lifecyclesWithin(replay, window, r.URL.Query().Get("source"))
+		// Lifecycles entered at the same time are sorted by source then ID, which the key
+		// preserves by joining them with a NUL, sorting before any character of a source ID
+		page, next, err := paginate(r, lifecycles, func(l analytics.Lifecycle) cursor {
+			return cursor{At: l.Entered, Key: l.Source + "\x00" + l.ID}
+		})
+		if err != nil {
+			writeProblem(w, r, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeList(w, r, page, meta{NextCursor: next})
+	}
+}
+
+// successorVersion links the responses of the unversioned API to their versioned equivalent
+func successorVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		successor := "/api/v1" + strings.TrimPrefix(r.URL.Path, "/api")
+		w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+		next.ServeHTTP(w, r)
+	})
+}
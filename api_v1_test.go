@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+func v1Router(store *history.Store) http.Handler {
+	router := chi.NewRouter()
+	router.Route("/api/v1", apiV1Routes(headline.NewAggregator(func() []headline.NewsClient { return nil }, headline.DefaultCachePolicy), store, newLifecycleReplay(store), nil))
+	return router
+}
+
+func TestAPIV1Pagination(t *testing.T) {
+	store, _ := history.Open("", 0)
+	now := time.Now()
+	store.Record(now.Add(-time.Hour), []headline.Response{{
+		Source: headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{
+			{Title: "Budget passed", URL: "http://test1.com"},
+			{Title: "Budget debate", URL: "http://test2.com"},
+			{Title: "Cricket win", URL: "http://test3.com"},
+		},
+	}})
+	store.Record(now.Add(-30*time.Minute), []headline.Response{{
+		Source:    headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{{Title: "Rain today", URL: "http://test4.com"}, {Title: "Budget passed", URL: "http://test1.com"}},
+	}})
+	router := v1Router(store)
+
+	seen := make(map[string]bool)
+	var titles []string
+	next := "/api/v1/articles?limit=2"
+	for pages := 0; next != ""; pages++ {
+		if pages == 3 {
+			t.Fatal("Expected the pages to end")
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", next, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body.String())
+		}
+		var response struct {
+			Data  []history.Article `json:"data"`
+			Meta  meta              `json:"meta"`
+			Links links             `json:"links"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatalf("Error decoding response: %v", err)
+		}
+		if response.Links.Self != next || response.Meta.Count == nil || *response.Meta.Count != len(response.Data) {
+			t.Errorf("Unexpected meta %+v and links %+v", response.Meta, response.Links)
+		}
+		for _, a := range response.Data {
+			titles = append(titles, a.Title)
+			seen[a.Title] = true
+		}
+		next = response.Links.Next
+
+		// Articles seen again between the requests keep their place
+		store.Record(now.Add(time.Duration(pages)*time.Second), []headline.Response{{
+			Source:    headline.SourceInfo{ID: "test"},
+			Headlines: []headline.NewsItem{{Title: "Budget debate", URL: "http://test2.com"}, {Title: "Cricket win", URL: "http://test3.com"}},
+		}})
+	}
+	if len(titles) != 4 || len(seen) != 4 || titles[0] != "Rain today" {
+		t.Errorf("Expected every article once, most recently first seen first, got %v", titles)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/v1/articles?q=budget", nil))
+	var response envelope
+	json.NewDecoder(rr.Body).Decode(&response)
+	if response.Meta.Count == nil || *response.Meta.Count != 2 || response.Links.Next != "" {
+		t.Errorf("Expected the two articles matching the search on one page, got %+v", response)
+	}
+}
+
+func TestAPIV1Problems(t *testing.T) {
+	store, _ := history.Open("", 0)
+	router := v1Router(store)
+
+	for _, tt := range []struct {
+		path   string
+		status int
+	}{
+		{"/api/v1/articles?cursor=invalid", http.StatusBadRequest},
+		{"/api/v1/edits?limit=1000", http.StatusBadRequest},
+		{"/api/v1/headlines?category=weather", http.StatusBadRequest},
+		{"/api/v1/articles/unknown/revisions", http.StatusNotFound},
+		{"/api/v1/snapshots", http.StatusNotFound},
+		{"/api/v1/unknown", http.StatusNotFound},
+	} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+		if rr.Code != tt.status || rr.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("Expected a %d problem for %s, got %d %s", tt.status, tt.path, rr.Code, rr.Header().Get("Content-Type"))
+			continue
+		}
+		var p problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatalf("Error decoding problem: %v", err)
+		}
+		if p.Status != tt.status || p.Title != http.StatusText(tt.status) || p.Type != "about:blank" {
+			t.Errorf("Unexpected problem for %s: %+v", tt.path, p)
+		}
+	}
+}
+
+func TestSuccessorVersion(t *testing.T) {
+	store, _ := history.Open("", 0)
+	rr := httptest.NewRecorder()
+	successorVersion(editsHandler(store)).ServeHTTP(rr, httptest.NewRequest("GET", "/api/edits", nil))
+	if link := rr.Header().Get("Link"); link != `</api/v1/edits>; rel="successor-version"` {
+		t.Errorf("Expected a link to the versioned endpoint, got %q", link)
+	}
+}
@@ -0,0 +1,371 @@
+// Package archive stores the raw front pages fetched from the sources together with the
+// headlines parsed from them, so that a front page can be looked at as it was at any time
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+// Page is a fetched page, stored under the SHA-256 hash of its content
+type Page struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash"`
+}
+
+// Snapshot is a source's front page and the headlines parsed from it at a point in time.
+// Snapshots are only recorded when the pages or the headlines changed.
+type Snapshot struct {
+	At     time.Time           `json:"at"`
+	Source string              `json:"source"`
+	Pages  []Page              `json:"pages"`
+	Items  []headline.NewsItem `json:"items"`
+}
+
+// Archive stores the pages gzip compressed in dir/pages, so that unchanged pages are stored
+// once, and appends the snapshots to dir/snapshots.jsonl
+type Archive struct {
+	dir       string
+	retention time.Duration
+
+	mu        sync.RWMutex
+	index     *os.File
+	snapshots []Snapshot
+	latest    map[string]int
+	// pages holds the hash of the pages fetched from each URL per source since the last Record
+	pages map[string]map[string]string
+}
+
+// Open opens the archive in dir, creating it if necessary. Snapshots older than retention,
+// and the pages only they refer to, are removed. Zero retention keeps them forever.
+func Open(dir string, retention time.Duration) (*Archive, error) {
+	a := &Archive{
+		dir:       dir,
+		retention: retention,
+		latest:    make(map[string]int),
+		pages:     make(map[string]map[string]string),
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "pages"), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive: %v", err)
+	}
+
+	snapshots, err := readSnapshots(a.indexPath())
+	if err != nil {
+		return nil, err
+	}
+	var cutoff time.Time
+	if retention > 0 {
+		cutoff = time.Now().Add(-retention)
+	}
+	kept := snapshots[:0]
+	for _, s := range snapshots {
+		if !s.At.Before(cutoff) {
+			kept = append(kept, s)
+		}
+	}
+	for _, s := range kept {
+		a.apply(s)
+	}
+
+	if len(kept) < len(snapshots) {
+		if err := a.compact(kept); err != nil {
+			return nil, err
+		}
+	}
+
+	a.index, err = os.OpenFile(a.indexPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	return a, nil
+}
+
+func (a *Archive) indexPath() string {
+	return filepath.Join(a.dir, "snapshots.jsonl")
+}
+
+func (a *Archive) pagePath(hash string) string {
+	return filepath.Join(a.dir, "pages", hash[:2], hash+".html.gz")
+}
+
+func readSnapshots(path string) ([]Snapshot, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %v", err)
+	}
+	defer f.Close()
+
+	var snapshots []Snapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var s Snapshot
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("failed to read archive %s line %d: %v", path, line, err)
+		}
+		snapshots = append(snapshots, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %v", path, err)
+	}
+	return snapshots, nil
+}
+
+// compact rewrites the index with the kept snapshots and removes the pages no longer referenced
+func (a *Archive) compact(kept []Snapshot) error {
+	tmp := a.indexPath() + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact archive: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	referenced := make(map[string]bool)
+	for _, s := range kept {
+		if err := enc.Encode(s); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to compact archive: %v", err)
+		}
+		for _, p := range s.Pages {
+			referenced[p.Hash] = true
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact archive: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to compact archive: %v", err)
+	}
+	if err := os.Rename(tmp, a.indexPath()); err != nil {
+		return fmt.Errorf("failed to compact archive: %v", err)
+	}
+
+	return filepath.WalkDir(filepath.Join(a.dir, "pages"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		if hash, ok := strings.CutSuffix(d.Name(), ".html.gz"); ok && !referenced[hash] {
+			return os.Remove(path)
+		}
+		return nil
+	})
+}
+
+// Close closes the snapshot index
+func (a *Archive) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.index == nil {
+		return nil
+	}
+	err := a.index.Close()
+	a.index = nil
+	return err
+}
+
+// PageHook returns a hook for headline.CachingHTTPClient.WithPageHook that stores the pages
+// fetched for the given source
+func (a *Archive) PageHook(source string) func(url string, body []byte) {
+	return func(url string, body []byte) {
+		hash, err := a.StorePage(body)
+		if err != nil {
+			// A missing page only leaves a gap in the archive
+			log.Printf("Failed to archive %s: %v", url, err)
+			return
+		}
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.pages[source] == nil {
+			a.pages[source] = make(map[string]string)
+		}
+		a.pages[source][url] = hash
+	}
+}
+
+// StorePage stores a page unless a page with the same content is stored already and returns its hash
+func (a *Archive) StorePage(body []byte) (string, error) {
+	sum := sha256.Sum256(body)
+	hash := hex.EncodeToString(sum[:])
+	path := a.pagePath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(body); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	// Written to a temporary file first so that a page is never read half written
+	tmp, err := os.CreateTemp(filepath.Dir(path), hash+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return hash, os.Rename(tmp.Name(), path)
+}
+
+// Page returns the content of the stored page with the given hash
+func (a *Archive) Page(hash string) ([]byte, error) {
+	if len(hash) != sha256.Size*2 {
+		return nil, fs.ErrNotExist
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return nil, fs.ErrNotExist
+	}
+	f, err := os.Open(a.pagePath(hash))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// Record stores a snapshot of every source whose pages or headlines changed. Failed and stale
+// responses are skipped since they do not reflect the current front page. A snapshot holds the
+// pages fetched for the source since the previous Record, or the pages of the previous snapshot
+// when none were fetched because they were served from the cache.
+func (a *Archive) Record(at time.Time, responses []headline.Response) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var errs []error
+	for _, resp := range responses {
+		source := resp.Source.ID
+		if source == "" {
+			source = resp.Source.Name
+		}
+		fetched := a.pages[source]
+		// The pages of this refresh are not carried over to the next one, so that a page no
+		// longer fetched, e.g. a removed section, is not archived with every later snapshot
+		delete(a.pages, source)
+		if !resp.Current() {
+			continue
+		}
+
+		s := Snapshot{At: at, Source: source, Pages: []Page{}, Items: resp.Headlines}
+		for url, hash := range fetched {
+			s.Pages = append(s.Pages, Page{URL: url, Hash: hash})
+		}
+		sort.Slice(s.Pages, func(i, j int) bool { return s.Pages[i].URL < s.Pages[j].URL })
+
+		if i, ok := a.latest[source]; ok {
+			previous := a.snapshots[i]
+			if len(fetched) == 0 {
+				s.Pages = previous.Pages
+			}
+			if reflect.DeepEqual(previous.Pages, s.Pages) && reflect.DeepEqual(previous.Items, s.Items) {
+				continue
+			}
+		}
+
+		a.apply(s)
+		if a.index != nil {
+			line, err := json.Marshal(s)
+			if err == nil {
+				_, err = a.index.Write(append(line, '\n'))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to write archive: %v", err))
+			}
+		}
+	}
+	a.prune(at)
+	return errors.Join(errs...)
+}
+
+// prune drops the expired snapshots from memory. Their files are removed the next time the
+// archive is opened.
+func (a *Archive) prune(now time.Time) {
+	if a.retention <= 0 {
+		return
+	}
+	cutoff := now.Add(-a.retention)
+	n := sort.Search(len(a.snapshots), func(i int) bool { return !a.snapshots[i].At.Before(cutoff) })
+	if n == 0 {
+		return
+	}
+	a.snapshots = append([]Snapshot(nil), a.snapshots[n:]...)
+	for source, i := range a.latest {
+		if i < n {
+			delete(a.latest, source)
+		} else {
+			a.latest[source] = i - n
+		}
+	}
+}
+
+func (a *Archive) apply(s Snapshot) {
+	a.latest[s.Source] = len(a.snapshots)
+	a.snapshots = append(a.snapshots, s)
+}
+
+// At returns the snapshot of each source, or only of the given source, that was current at the
+// given time. Sources without a snapshot before that time are left out.
+func (a *Archive) At(source string, at time.Time) []Snapshot {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var sources []string
+	current := make(map[string]Snapshot)
+	for _, s := range a.snapshots {
+		if s.At.After(at) || (source != "" && s.Source != source) {
+			continue
+		}
+		if _, ok := current[s.Source]; !ok {
+			sources = append(sources, s.Source)
+		}
+		current[s.Source] = s
+	}
+
+	snapshots := make([]Snapshot, 0, len(sources))
+	for _, source := range sources {
+		snapshots = append(snapshots, current[source])
+	}
+	return snapshots
+}
@@ -0,0 +1,140 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+func countPages(t *testing.T, dir string) int {
+	t.Helper()
+	n := 0
+	filepath.WalkDir(filepath.Join(dir, "pages"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			n++
+		}
+		return nil
+	})
+	return n
+}
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	a, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Error opening archive: %v", err)
+	}
+
+	hook := a.PageHook("test")
+	response := func(titles ...string) []headline.Response {
+		var items []headline.NewsItem
+		for _, title := range titles {
+			items = append(items, headline.NewsItem{Title: title, URL: "http://test.com/" + title})
+		}
+		return []headline.Response{{Source: headline.SourceInfo{ID: "test"}, Headlines: items}}
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	hook("http://test.com/", []byte("<html>first</html>"))
+	a.Record(start, response("first"))
+
+	// An unchanged page is neither stored nor recorded again
+	hook("http://test.com/", []byte("<html>first</html>"))
+	a.Record(start.Add(time.Minute), response("first"))
+
+	hook("http://test.com/", []byte("<html>second</html>"))
+	a.Record(start.Add(2*time.Minute), response("second"))
+
+	if n := countPages(t, dir); n != 2 {
+		t.Errorf("Expected 2 stored pages, got %d", n)
+	}
+
+	snapshots := a.At("", start.Add(90*time.Second))
+	if len(snapshots) != 1 || !snapshots[0].At.Equal(start) || snapshots[0].Items[0].Title != "first" {
+		t.Fatalf("Expected the first snapshot, got %+v", snapshots)
+	}
+	body, err := a.Page(snapshots[0].Pages[0].Hash)
+	if err != nil || string(body) != "<html>first</html>" {
+		t.Errorf("Expected the first page, got %q, %v", body, err)
+	}
+
+	if snapshots := a.At("test", start.Add(-time.Minute)); len(snapshots) != 0 {
+		t.Errorf("Expected no snapshot before the first one, got %+v", snapshots)
+	}
+	if _, err := a.Page("../../etc/passwd"); err == nil {
+		t.Error("Expected an error for an invalid hash")
+	}
+	a.Close()
+
+	// The snapshots are read back from disk
+	reopened, err := Open(dir, 0)
+	if err != nil {
+		t.Fatalf("Error reopening archive: %v", err)
+	}
+	if snapshots := reopened.At("test", start.Add(time.Hour)); len(snapshots) != 1 || snapshots[0].Items[0].Title != "second" {
+		t.Errorf("Expected the second snapshot, got %+v", snapshots)
+	}
+	reopened.Close()
+
+	// Expired snapshots and their pages are removed
+	expired, err := Open(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("Error reopening archive: %v", err)
+	}
+	defer expired.Close()
+	if snapshots := expired.At("", time.Now()); len(snapshots) != 0 {
+		t.Errorf("Expected the snapshots to expire, got %+v", snapshots)
+	}
+	if n := countPages(t, dir); n != 0 {
+		t.Errorf("Expected the pages to be removed, got %d", n)
+	}
+}
+
+func TestArchivePagesPerRefresh(t *testing.T) {
+	a, err := Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Error opening archive: %v", err)
+	}
+	defer a.Close()
+	hook := a.PageHook("test")
+	response := func(title string) []headline.Response {
+		return []headline.Response{{Source: headline.SourceInfo{ID: "test"}, Headlines: []headline.NewsItem{{Title: title, URL: "http://test.com/" + title}}}}
+	}
+	urls := func(s Snapshot) []string {
+		var urls []string
+		for _, p := range s.Pages {
+			urls = append(urls, p.URL)
+		}
+		return urls
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	hook("http://test.com/", []byte("<html>first</html>"))
+	hook("http://test.com/sports", []byte("<html>sports</html>"))
+	a.Record(start, response("first"))
+
+	// A section no longer fetched is not part of the next snapshot
+	hook("http://test.com/", []byte("<html>second</html>"))
+	a.Record(start.Add(time.Minute), response("second"))
+	if s := a.At("test", start.Add(time.Minute)); len(s) != 1 || !reflect.DeepEqual(urls(s[0]), []string{"http://test.com/"}) {
+		t.Errorf("Expected only the front page, got %+v", s)
+	}
+
+	// Nor are the pages of a failed refresh
+	hook("http://test.com/", []byte("<html>error</html>"))
+	a.Record(start.Add(2*time.Minute), []headline.Response{{Source: headline.SourceInfo{ID: "test"}, Error: &headline.SourceError{Type: headline.ErrorTypeFetchFailed}}})
+
+	// Pages served from the cache are not fetched again, so the snapshot keeps the previous ones
+	a.Record(start.Add(3*time.Minute), response("third"))
+	s := a.At("test", start.Add(3*time.Minute))
+	if len(s) != 1 || s[0].Items[0].Title != "third" || len(s[0].Pages) != 1 {
+		t.Fatalf("Expected the new headlines with the cached page, got %+v", s)
+	}
+	if body, _ := a.Page(s[0].Pages[0].Hash); string(body) != "<html>second</html>" {
+		t.Errorf("Expected the page of the previous snapshot, got %q", body)
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shaharia-lab/headlines/archive"
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+// snapshotResponse is an archived front page in the format of /api/headlines
+type snapshotResponse struct {
+	headline.Response
+	SnapshotAt time.Time      `json:"snapshotAt"`
+	Pages      []archivedPage `json:"pages"`
+}
+
+// archivedPage links to the raw page a snapshot was parsed from
+type archivedPage struct {
+	archive.Page
+	Href string `json:"href"`
+}
+
+// errArchiveDisabled is returned for requests of archived pages when the archive is disabled
+var errArchiveDisabled = errors.New("The archive is disabled")
+
+// snapshots returns the front pages as they were at the time given by the at parameter of the request
+func snapshots(pages *archive.Archive, sources func() []headline.NewsClient, r *http.Request) ([]snapshotResponse, error) {
+	if pages == nil {
+		return nil, errArchiveDisabled
+	}
+
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, value); err != nil {
+			return nil, errors.New("Invalid at, expected an RFC 3339 time such as 2024-01-01T12:00:00Z")
+		}
+	}
+
+	infos := make(map[string]headline.SourceInfo)
+	for _, source := range sources() {
+		info := source.SourceInfo()
+		infos[info.ID] = info
+	}
+
+	responses := []snapshotResponse{}
+	for _, snapshot := range pages.At(r.URL.Query().Get("source"), at) {
+		info, ok := infos[snapshot.Source]
+		if !ok {
+			info = registeredSourceInfo(snapshot.Source)
+		}
+		response := snapshotResponse{
+			Response:   headline.Response{Source: info, Headlines: snapshot.Items},
+			SnapshotAt: snapshot.At,
+			Pages:      []archivedPage{},
+		}
+		for _, page := range snapshot.Pages {
+			response.Pages = append(response.Pages, archivedPage{Page: page, Href: "/api/snapshots/pages/" + page.Hash})
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// snapshotsHandler serves the front pages as they were at the time given by the at parameter,
+// in RFC 3339 format. The current front pages are served without it.
+func snapshotsHandler(pages *archive.Archive, sources func() []headline.NewsClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responses, err := snapshots(pages, sources, r)
+		if errors.Is(err, errArchiveDisabled) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, responses)
+	}
+}
+
+// snapshotPageHandler serves an archived raw page. Scripts of the page are not run, since it is
+// served from this origin.
+func snapshotPageHandler(pages *archive.Archive) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if pages == nil {
+			http.Error(w, "The archive is disabled", http.StatusNotFound)
+			return
+		}
+
+		body, err := pages.Page(chi.URLParam(r, "hash"))
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "Page not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Could not read page", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Write(body)
+	}
+}
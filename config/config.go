@@ -0,0 +1,641 @@
+// Package config provides the layered configuration for the headlines server.
+//
+// Settings are resolved in order of increasing precedence: built-in defaults,
+// a YAML or TOML configuration file, HEADLINES_* environment variables and
+// finally command-line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of all environment variables read by the configuration
+const EnvPrefix = "HEADLINES_"
+
+// Config represents the complete configuration of the headlines server
+type Config struct {
+	Server  ServerConfig   `yaml:"server" toml:"server"`
+	Cache   CacheConfig    `yaml:"cache" toml:"cache"`
+	Scraper ScraperConfig  `yaml:"scraper" toml:"scraper"`
+	History HistoryConfig  `yaml:"history" toml:"history"`
+	Archive ArchiveConfig  `yaml:"archive" toml:"archive"`
+	Sources []SourceConfig `yaml:"sources" toml:"sources"`
+}
+
+// ArchiveConfig represents the archive of the raw front pages
+type ArchiveConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Dir is the directory the pages and snapshots are stored in
+	Dir string `yaml:"dir" toml:"dir"`
+	// Retention is how long snapshots are kept. Zero keeps them forever.
+	Retention Duration `yaml:"retention" toml:"retention"`
+}
+
+// HistoryConfig represents where the headlines seen over time are stored
+type HistoryConfig struct {
+	// Path is the JSON Lines file the history is persisted to. The history is kept in memory only when empty.
+	Path string `yaml:"path" toml:"path"`
+	// Retention is how long the history is kept. Zero keeps it forever.
+	Retention Duration `yaml:"retention" toml:"retention"`
+}
+
+// ServerConfig represents the HTTP server settings
+type ServerConfig struct {
+	Port           int      `yaml:"port" toml:"port"`
+	AllowedOrigins []string `yaml:"allowed_origins" toml:"allowed_origins"`
+	// ConfigWatchInterval is how often the config file is checked for changes. Zero disables watching.
+	ConfigWatchInterval Duration `yaml:"config_watch_interval" toml:"config_watch_interval"`
+}
+
+// CacheConfig represents the caching settings
+type CacheConfig struct {
+	// Duration is how long aggregated headlines are served from cache
+	Duration Duration `yaml:"duration" toml:"duration"`
+	// HTTPTTL is how long fetched pages are kept by the HTTP client
+	HTTPTTL Duration `yaml:"http_ttl" toml:"http_ttl"`
+	// StaleMaxAge is how long the last good headlines of a failing source are served. Zero disables it.
+	StaleMaxAge Duration `yaml:"stale_max_age" toml:"stale_max_age"`
+}
+
+// ScraperConfig represents the settings of the shared HTTP client used by all sources
+type ScraperConfig struct {
+	Timeout   Duration        `yaml:"timeout" toml:"timeout"`
+	UserAgent string          `yaml:"user_agent" toml:"user_agent"`
+	RateLimit RateLimitConfig `yaml:"rate_limit" toml:"rate_limit"`
+	Robots    RobotsConfig    `yaml:"robots" toml:"robots"`
+	Retry     RetryConfig     `yaml:"retry" toml:"retry"`
+	// CircuitBreaker is applied to every source individually
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker" toml:"circuit_breaker"`
+	// UserAgents are rotated through by the requests when set. UserAgent is still used for robots.txt.
+	UserAgents []string        `yaml:"user_agents,omitempty" toml:"user_agents,omitempty"`
+	Transport  TransportConfig `yaml:"transport" toml:"transport"`
+	// Offline serves the pages from recorded traffic instead of the network
+	Offline OfflineConfig `yaml:"offline" toml:"offline"`
+}
+
+// TransportConfig represents the network settings of the HTTP client
+type TransportConfig struct {
+	// Proxy is the URL of an http, https or socks5 proxy. HTTP_PROXY, HTTPS_PROXY and NO_PROXY are used when empty.
+	Proxy string `yaml:"proxy,omitempty" toml:"proxy,omitempty"`
+	// CABundle is a PEM file of CA certificates trusted in addition to the system's
+	CABundle string `yaml:"ca_bundle,omitempty" toml:"ca_bundle,omitempty"`
+	// MaxIdleConns is the maximum number of idle connections across all hosts. Zero means unlimited.
+	MaxIdleConns int `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept per host
+	MaxIdleConnsPerHost int `yaml:"max_idle_conns_per_host" toml:"max_idle_conns_per_host"`
+	// MaxConnsPerHost limits the connections per host, including those in use. Zero means unlimited.
+	MaxConnsPerHost int `yaml:"max_conns_per_host" toml:"max_conns_per_host"`
+	// IdleConnTimeout is how long an idle connection is kept
+	IdleConnTimeout Duration `yaml:"idle_conn_timeout" toml:"idle_conn_timeout"`
+	// HTTP2 allows HTTP/2 to be negotiated
+	HTTP2 bool `yaml:"http2" toml:"http2"`
+}
+
+// OfflineConfig represents the recorded traffic pages are served from. The network is used when both are empty.
+type OfflineConfig struct {
+	// Dir is a directory of saved pages, stored as <host>/<path>
+	Dir string `yaml:"dir,omitempty" toml:"dir,omitempty"`
+	// HAR is an HTTP Archive recorded by a browser or a proxy
+	HAR string `yaml:"har,omitempty" toml:"har,omitempty"`
+}
+
+// Enabled reports whether pages are served from recorded traffic
+func (o OfflineConfig) Enabled() bool {
+	return o.Dir != "" || o.HAR != ""
+}
+
+// RetryConfig represents how failed requests are retried
+type RetryConfig struct {
+	// MaxAttempts is the total number of attempts per request. 1 disables retries.
+	MaxAttempts    int      `yaml:"max_attempts" toml:"max_attempts"`
+	InitialBackoff Duration `yaml:"initial_backoff" toml:"initial_backoff"`
+	MaxBackoff     Duration `yaml:"max_backoff" toml:"max_backoff"`
+	// Jitter randomizes each backoff by up to this fraction, between 0 and 1
+	Jitter float64 `yaml:"jitter" toml:"jitter"`
+}
+
+// CircuitBreakerConfig represents the per-source circuit breaker settings
+type CircuitBreakerConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// FailureThreshold is the number of consecutive failed fetches that opens the circuit
+	FailureThreshold int `yaml:"failure_threshold" toml:"failure_threshold"`
+	// OpenTimeout is how long a source is not fetched once its circuit opened
+	OpenTimeout Duration `yaml:"open_timeout" toml:"open_timeout"`
+}
+
+// RobotsConfig represents the robots.txt compliance settings
+type RobotsConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// TTL is how long a host's robots.txt is cached
+	TTL Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// RateLimitConfig represents the politeness controls applied to every host
+type RateLimitConfig struct {
+	// RequestsPerSecond is the sustained request rate per host. Zero disables the limit.
+	RequestsPerSecond float64 `yaml:"requests_per_second" toml:"requests_per_second"`
+	// Burst is the number of requests per host that may exceed the rate
+	Burst int `yaml:"burst" toml:"burst"`
+	// MaxConcurrentPerHost is the maximum number of concurrent requests per host. Zero means unlimited.
+	MaxConcurrentPerHost int `yaml:"max_concurrent_per_host" toml:"max_concurrent_per_host"`
+	// MinDelay is the minimum delay between two requests to the same host
+	MinDelay Duration `yaml:"min_delay" toml:"min_delay"`
+	// MaxRetryAfter is the longest Retry-After delay waited for before retrying a 429 or 503 response
+	MaxRetryAfter Duration `yaml:"max_retry_after" toml:"max_retry_after"`
+}
+
+// SourceConfig represents a single news source
+type SourceConfig struct {
+	// ID identifies the source
+	ID string `yaml:"id" toml:"id"`
+	// Type is the ID of the registered source to create. It defaults to ID.
+	Type string `yaml:"type,omitempty" toml:"type,omitempty"`
+	// URL overrides the page that is scraped. The source's homepage is used when empty.
+	URL     string `yaml:"url,omitempty" toml:"url,omitempty"`
+	Enabled *bool  `yaml:"enabled,omitempty" toml:"enabled,omitempty"`
+	// Proxy overrides scraper.transport.proxy for this source
+	Proxy string `yaml:"proxy,omitempty" toml:"proxy,omitempty"`
+	// IgnoreRobots fetches the source regardless of its robots.txt.
+	// Only set it when the site has explicitly permitted scraping.
+	IgnoreRobots bool `yaml:"ignore_robots,omitempty" toml:"ignore_robots,omitempty"`
+	// Options are source specific settings passed to the source factory
+	Options map[string]string `yaml:"options,omitempty" toml:"options,omitempty"`
+	// Sections are section pages fetched in addition to the homepage
+	Sections []SectionConfig `yaml:"sections,omitempty" toml:"sections,omitempty"`
+}
+
+// SectionConfig is a section page of a news source
+type SectionConfig struct {
+	// Category is the section name, mapped to the category taxonomy, e.g. sports or খেলা
+	Category string `yaml:"category" toml:"category"`
+	URL      string `yaml:"url" toml:"url"`
+}
+
+// SourceType returns the ID of the registered source to create
+func (s SourceConfig) SourceType() string {
+	if s.Type != "" {
+		return s.Type
+	}
+	return s.ID
+}
+
+// IsEnabled reports whether the source is enabled. Sources are enabled unless explicitly disabled.
+func (s SourceConfig) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// Duration is a time.Duration that is read from and written as a string such as "5s"
+type Duration time.Duration
+
+// UnmarshalText parses a duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// String returns the duration formatted as a string
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// minHistoryRetention is the shortest history the trends can be computed from: a window of a
+// day and a baseline of the week before it
+const minHistoryRetention = 8 * 24 * time.Hour
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:                8080,
+			AllowedOrigins:      []string{"*"},
+			ConfigWatchInterval: Duration(5 * time.Second),
+		},
+		Cache: CacheConfig{
+			Duration:    Duration(1 * time.Minute),
+			HTTPTTL:     Duration(1 * time.Minute),
+			StaleMaxAge: Duration(1 * time.Hour),
+		},
+		Scraper: ScraperConfig{
+			Timeout:   Duration(5 * time.Second),
+			UserAgent: "headlines/1.0",
+			RateLimit: RateLimitConfig{
+				RequestsPerSecond:    1,
+				Burst:                3,
+				MaxConcurrentPerHost: 2,
+				MinDelay:             Duration(250 * time.Millisecond),
+				MaxRetryAfter:        Duration(10 * time.Second),
+			},
+			Robots: RobotsConfig{
+				Enabled: true,
+				TTL:     Duration(1 * time.Hour),
+			},
+			Retry: RetryConfig{
+				MaxAttempts:    3,
+				InitialBackoff: Duration(500 * time.Millisecond),
+				MaxBackoff:     Duration(5 * time.Second),
+				Jitter:         0.2,
+			},
+			CircuitBreaker: CircuitBreakerConfig{
+				Enabled:          true,
+				FailureThreshold: 3,
+				OpenTimeout:      Duration(2 * time.Minute),
+			},
+			Transport: TransportConfig{
+				MaxIdleConns:        100,
+				MaxIdleConnsPerHost: 2,
+				IdleConnTimeout:     Duration(90 * time.Second),
+				HTTP2:               true,
+			},
+		},
+		History: HistoryConfig{
+			Retention: Duration(minHistoryRetention),
+		},
+		Archive: ArchiveConfig{
+			Dir:       "archive",
+			Retention: Duration(30 * 24 * time.Hour),
+		},
+		Sources: []SourceConfig{
+			{ID: "prothomalo", URL: "https://www.prothomalo.com/"},
+			{ID: "mzamin", URL: "https://mzamin.com/"},
+			{ID: "dailystarbangla", URL: "https://bangla.thedailystar.net/"},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the optional file at path and the environment.
+// The resulting configuration is not validated so that flags can still be applied on top of it.
+func Load(path string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.LoadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.ApplyEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// LoadFile reads the YAML or TOML file at path on top of the current configuration.
+// The format is chosen by the file extension.
+func (c *Config) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		_, err = toml.Decode(string(data), c)
+	default:
+		return fmt.Errorf("unsupported config file format %q, use .yaml, .yml or .toml", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// ApplyEnv overrides the configuration with HEADLINES_* environment variables.
+// HEADLINES_SOURCES restricts the enabled sources to a comma separated list of IDs.
+func (c *Config) ApplyEnv(lookupEnv func(string) (string, bool)) error {
+	var errs []error
+	env := func(name string, apply func(string) error) {
+		value, ok := lookupEnv(EnvPrefix + name)
+		if !ok {
+			return
+		}
+		if err := apply(strings.TrimSpace(value)); err != nil {
+			errs = append(errs, fmt.Errorf("%s%s: %v", EnvPrefix, name, err))
+		}
+	}
+	duration := func(d *Duration) func(string) error {
+		return func(v string) error {
+			return d.UnmarshalText([]byte(v))
+		}
+	}
+
+	env("SERVER_PORT", func(v string) error {
+		port, err := strconv.Atoi(v)
+		c.Server.Port = port
+		return err
+	})
+	env("SERVER_ALLOWED_ORIGINS", func(v string) error {
+		c.Server.AllowedOrigins = splitList(v)
+		return nil
+	})
+	env("SERVER_CONFIG_WATCH_INTERVAL", duration(&c.Server.ConfigWatchInterval))
+	env("CACHE_DURATION", duration(&c.Cache.Duration))
+	env("CACHE_HTTP_TTL", duration(&c.Cache.HTTPTTL))
+	env("CACHE_STALE_MAX_AGE", duration(&c.Cache.StaleMaxAge))
+	env("SCRAPER_TIMEOUT", duration(&c.Scraper.Timeout))
+	env("SCRAPER_USER_AGENT", func(v string) error {
+		c.Scraper.UserAgent = v
+		return nil
+	})
+	env("SCRAPER_RATE_LIMIT_REQUESTS_PER_SECOND", func(v string) error {
+		rps, err := strconv.ParseFloat(v, 64)
+		c.Scraper.RateLimit.RequestsPerSecond = rps
+		return err
+	})
+	env("SCRAPER_RATE_LIMIT_BURST", func(v string) error {
+		n, err := strconv.Atoi(v)
+		c.Scraper.RateLimit.Burst = n
+		return err
+	})
+	env("SCRAPER_RATE_LIMIT_MAX_CONCURRENT_PER_HOST", func(v string) error {
+		n, err := strconv.Atoi(v)
+		c.Scraper.RateLimit.MaxConcurrentPerHost = n
+		return err
+	})
+	env("SCRAPER_RATE_LIMIT_MIN_DELAY", duration(&c.Scraper.RateLimit.MinDelay))
+	env("SCRAPER_RATE_LIMIT_MAX_RETRY_AFTER", duration(&c.Scraper.RateLimit.MaxRetryAfter))
+	env("SCRAPER_ROBOTS_ENABLED", func(v string) error {
+		enabled, err := strconv.ParseBool(v)
+		c.Scraper.Robots.Enabled = enabled
+		return err
+	})
+	env("SCRAPER_ROBOTS_TTL", duration(&c.Scraper.Robots.TTL))
+	env("SCRAPER_RETRY_MAX_ATTEMPTS", func(v string) error {
+		n, err := strconv.Atoi(v)
+		c.Scraper.Retry.MaxAttempts = n
+		return err
+	})
+	env("SCRAPER_RETRY_INITIAL_BACKOFF", duration(&c.Scraper.Retry.InitialBackoff))
+	env("SCRAPER_RETRY_MAX_BACKOFF", duration(&c.Scraper.Retry.MaxBackoff))
+	env("SCRAPER_RETRY_JITTER", func(v string) error {
+		jitter, err := strconv.ParseFloat(v, 64)
+		c.Scraper.Retry.Jitter = jitter
+		return err
+	})
+	env("SCRAPER_CIRCUIT_BREAKER_ENABLED", func(v string) error {
+		enabled, err := strconv.ParseBool(v)
+		c.Scraper.CircuitBreaker.Enabled = enabled
+		return err
+	})
+	env("SCRAPER_CIRCUIT_BREAKER_FAILURE_THRESHOLD", func(v string) error {
+		n, err := strconv.Atoi(v)
+		c.Scraper.CircuitBreaker.FailureThreshold = n
+		return err
+	})
+	env("SCRAPER_CIRCUIT_BREAKER_OPEN_TIMEOUT", duration(&c.Scraper.CircuitBreaker.OpenTimeout))
+	env("SCRAPER_USER_AGENTS", func(v string) error {
+		c.Scraper.UserAgents = splitList(v)
+		return nil
+	})
+	env("SCRAPER_TRANSPORT_PROXY", func(v string) error {
+		c.Scraper.Transport.Proxy = v
+		return nil
+	})
+	env("SCRAPER_TRANSPORT_CA_BUNDLE", func(v string) error {
+		c.Scraper.Transport.CABundle = v
+		return nil
+	})
+	env("SCRAPER_TRANSPORT_MAX_IDLE_CONNS", func(v string) error {
+		n, err := strconv.Atoi(v)
+		c.Scraper.Transport.MaxIdleConns = n
+		return err
+	})
+	env("SCRAPER_TRANSPORT_MAX_IDLE_CONNS_PER_HOST", func(v string) error {
+		n, err := strconv.Atoi(v)
+		c.Scraper.Transport.MaxIdleConnsPerHost = n
+		return err
+	})
+	env("SCRAPER_TRANSPORT_MAX_CONNS_PER_HOST", func(v string) error {
+		n, err := strconv.Atoi(v)
+		c.Scraper.Transport.MaxConnsPerHost = n
+		return err
+	})
+	env("SCRAPER_TRANSPORT_IDLE_CONN_TIMEOUT", duration(&c.Scraper.Transport.IdleConnTimeout))
+	env("SCRAPER_TRANSPORT_HTTP2", func(v string) error {
+		enabled, err := strconv.ParseBool(v)
+		c.Scraper.Transport.HTTP2 = enabled
+		return err
+	})
+	env("SCRAPER_OFFLINE_DIR", func(v string) error {
+		c.Scraper.Offline.Dir = v
+		return nil
+	})
+	env("SCRAPER_OFFLINE_HAR", func(v string) error {
+		c.Scraper.Offline.HAR = v
+		return nil
+	})
+	env("HISTORY_PATH", func(v string) error {
+		c.History.Path = v
+		return nil
+	})
+	env("HISTORY_RETENTION", duration(&c.History.Retention))
+	env("ARCHIVE_ENABLED", func(v string) error {
+		enabled, err := strconv.ParseBool(v)
+		c.Archive.Enabled = enabled
+		return err
+	})
+	env("ARCHIVE_DIR", func(v string) error {
+		c.Archive.Dir = v
+		return nil
+	})
+	env("ARCHIVE_RETENTION", duration(&c.Archive.Retention))
+	env("SOURCES", func(v string) error {
+		return c.EnableOnly(splitList(v))
+	})
+
+	return errors.Join(errs...)
+}
+
+// EnableOnly enables the sources with the given IDs, in the given order, and disables all others
+func (c *Config) EnableOnly(ids []string) error {
+	byID := make(map[string]SourceConfig, len(c.Sources))
+	for _, s := range c.Sources {
+		byID[s.ID] = s
+	}
+
+	enabled := true
+	sources := make([]SourceConfig, 0, len(c.Sources))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		s, ok := byID[id]
+		if !ok {
+			return fmt.Errorf("unknown source %q", id)
+		}
+		s.Enabled = &enabled
+		sources = append(sources, s)
+		seen[id] = true
+	}
+
+	disabled := false
+	for _, s := range c.Sources {
+		if !seen[s.ID] {
+			s.Enabled = &disabled
+			sources = append(sources, s)
+		}
+	}
+	c.Sources = sources
+	return nil
+}
+
+// EnabledSources returns the enabled sources in their configured order
+func (c *Config) EnabledSources() []SourceConfig {
+	var sources []SourceConfig
+	for _, s := range c.Sources {
+		if s.IsEnabled() {
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
+// Validate checks the configuration and reports all problems found
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if len(c.Server.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("server.allowed_origins must not be empty"))
+	}
+	if c.Server.ConfigWatchInterval < 0 {
+		errs = append(errs, fmt.Errorf("server.config_watch_interval must not be negative, got %s", c.Server.ConfigWatchInterval))
+	}
+	if c.Cache.Duration <= 0 {
+		errs = append(errs, fmt.Errorf("cache.duration must be positive, got %s", c.Cache.Duration))
+	}
+	if c.Cache.HTTPTTL < 0 {
+		errs = append(errs, fmt.Errorf("cache.http_ttl must not be negative, got %s", c.Cache.HTTPTTL))
+	}
+	if c.Cache.StaleMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("cache.stale_max_age must not be negative, got %s", c.Cache.StaleMaxAge))
+	}
+	if c.Scraper.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("scraper.timeout must be positive, got %s", c.Scraper.Timeout))
+	}
+	if strings.TrimSpace(c.Scraper.UserAgent) == "" {
+		errs = append(errs, errors.New("scraper.user_agent must not be empty"))
+	}
+	if rl := c.Scraper.RateLimit; rl.RequestsPerSecond < 0 || rl.Burst < 0 || rl.MaxConcurrentPerHost < 0 || rl.MinDelay < 0 || rl.MaxRetryAfter < 0 {
+		errs = append(errs, errors.New("scraper.rate_limit settings must not be negative"))
+	}
+	if c.Scraper.Robots.Enabled && c.Scraper.Robots.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("scraper.robots.ttl must be positive, got %s", c.Scraper.Robots.TTL))
+	}
+	if r := c.Scraper.Retry; r.MaxAttempts < 1 || r.InitialBackoff < 0 || r.MaxBackoff < 0 || r.Jitter < 0 || r.Jitter > 1 {
+		errs = append(errs, errors.New("scraper.retry.max_attempts must be at least 1, backoffs must not be negative and jitter must be between 0 and 1"))
+	}
+	if cb := c.Scraper.CircuitBreaker; cb.Enabled && (cb.FailureThreshold < 1 || cb.OpenTimeout <= 0) {
+		errs = append(errs, errors.New("scraper.circuit_breaker.failure_threshold must be at least 1 and open_timeout must be positive"))
+	}
+	for _, ua := range c.Scraper.UserAgents {
+		if strings.TrimSpace(ua) == "" {
+			errs = append(errs, errors.New("scraper.user_agents must not contain empty user agents"))
+			break
+		}
+	}
+	if t := c.Scraper.Transport; t.MaxIdleConns < 0 || t.MaxIdleConnsPerHost < 0 || t.MaxConnsPerHost < 0 || t.IdleConnTimeout < 0 {
+		errs = append(errs, errors.New("scraper.transport pool settings must not be negative"))
+	}
+	if p := c.Scraper.Transport.Proxy; p != "" && !proxyURL(p) {
+		errs = append(errs, fmt.Errorf("scraper.transport.proxy %q must be an http, https or socks5 URL", p))
+	}
+	if c.Scraper.Offline.Dir != "" && c.Scraper.Offline.HAR != "" {
+		errs = append(errs, errors.New("only one of scraper.offline.dir and scraper.offline.har may be set"))
+	}
+	if c.History.Retention < 0 {
+		errs = append(errs, fmt.Errorf("history.retention must not be negative, got %s", c.History.Retention))
+	} else if c.History.Retention > 0 && time.Duration(c.History.Retention) < minHistoryRetention {
+		errs = append(errs, fmt.Errorf("history.retention must be zero or at least %s to cover the trends window and baseline, got %s", Duration(minHistoryRetention), c.History.Retention))
+	}
+	if c.Archive.Enabled && strings.TrimSpace(c.Archive.Dir) == "" {
+		errs = append(errs, errors.New("archive.dir must not be empty when the archive is enabled"))
+	}
+	if c.Archive.Retention < 0 {
+		errs = append(errs, fmt.Errorf("archive.retention must not be negative, got %s", c.Archive.Retention))
+	}
+
+	ids := make(map[string]bool, len(c.Sources))
+	for i, s := range c.Sources {
+		if s.ID == "" {
+			errs = append(errs, fmt.Errorf("sources[%d].id must not be empty", i))
+		} else if ids[s.ID] {
+			errs = append(errs, fmt.Errorf("sources[%d].id %q is duplicated", i, s.ID))
+		}
+		ids[s.ID] = true
+
+		if s.Proxy != "" && !proxyURL(s.Proxy) {
+			errs = append(errs, fmt.Errorf("sources[%d].proxy %q must be an http, https or socks5 URL", i, s.Proxy))
+		}
+		if s.URL != "" && !absoluteURL(s.URL) {
+			errs = append(errs, fmt.Errorf("sources[%d].url %q must be an absolute http(s) URL", i, s.URL))
+		}
+		for j, section := range s.Sections {
+			if strings.TrimSpace(section.Category) == "" {
+				errs = append(errs, fmt.Errorf("sources[%d].sections[%d].category must not be empty", i, j))
+			}
+			if !absoluteURL(section.URL) {
+				errs = append(errs, fmt.Errorf("sources[%d].sections[%d].url %q must be an absolute http(s) URL", i, j, section.URL))
+			}
+		}
+	}
+	if len(c.EnabledSources()) == 0 {
+		errs = append(errs, errors.New("at least one source must be enabled"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func absoluteURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func proxyURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return true
+	}
+	return false
+}
+
+// YAML returns the configuration encoded as YAML
+func (c *Config) YAML() ([]byte, error) {
+	return yaml.Marshal(c)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
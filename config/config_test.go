@@ -0,0 +1,175 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Expected default config to be valid, got %v", err)
+	}
+}
+
+func TestLoadLayering(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "headlines.yaml")
+	err := os.WriteFile(path, []byte(`
+server:
+  port: 9090
+cache:
+  duration: 2m
+scraper:
+  user_agent: file-agent
+sources:
+  - id: prothomalo
+    url: https://www.prothomalo.com/
+  - id: mzamin
+    url: https://mzamin.com/
+    enabled: false
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{
+		"HEADLINES_SCRAPER_USER_AGENT": "env-agent",
+		"HEADLINES_SCRAPER_TIMEOUT":    "10s",
+	}
+	cfg, err := Load(path, func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	})
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+
+	if cfg.Server.Port != 9090 {
+		t.Errorf("Expected port 9090 from file, got %d", cfg.Server.Port)
+	}
+	if time.Duration(cfg.Cache.Duration) != 2*time.Minute {
+		t.Errorf("Expected cache duration 2m from file, got %s", cfg.Cache.Duration)
+	}
+	if cfg.Scraper.UserAgent != "env-agent" {
+		t.Errorf("Expected user agent from environment, got %s", cfg.Scraper.UserAgent)
+	}
+	if time.Duration(cfg.Scraper.Timeout) != 10*time.Second {
+		t.Errorf("Expected timeout 10s from environment, got %s", cfg.Scraper.Timeout)
+	}
+	if enabled := cfg.EnabledSources(); len(enabled) != 1 || enabled[0].ID != "prothomalo" {
+		t.Errorf("Expected only prothomalo to be enabled, got %+v", enabled)
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		got   func(*Config) any
+		want  any
+	}{
+		{"SCRAPER_RATE_LIMIT_BURST", "5", func(c *Config) any { return c.Scraper.RateLimit.Burst }, 5},
+		{"SCRAPER_RATE_LIMIT_MAX_RETRY_AFTER", "30s", func(c *Config) any { return c.Scraper.RateLimit.MaxRetryAfter }, Duration(30 * time.Second)},
+		{"SCRAPER_ROBOTS_TTL", "2h", func(c *Config) any { return c.Scraper.Robots.TTL }, Duration(2 * time.Hour)},
+		{"SCRAPER_CIRCUIT_BREAKER_FAILURE_THRESHOLD", "5", func(c *Config) any { return c.Scraper.CircuitBreaker.FailureThreshold }, 5},
+		{"SCRAPER_CIRCUIT_BREAKER_OPEN_TIMEOUT", "5m", func(c *Config) any { return c.Scraper.CircuitBreaker.OpenTimeout }, Duration(5 * time.Minute)},
+		{"SCRAPER_RETRY_INITIAL_BACKOFF", "1s", func(c *Config) any { return c.Scraper.Retry.InitialBackoff }, Duration(time.Second)},
+		{"SCRAPER_RETRY_MAX_BACKOFF", "1m", func(c *Config) any { return c.Scraper.Retry.MaxBackoff }, Duration(time.Minute)},
+		{"SCRAPER_RETRY_JITTER", "0.5", func(c *Config) any { return c.Scraper.Retry.Jitter }, 0.5},
+		{"SCRAPER_TRANSPORT_MAX_IDLE_CONNS", "50", func(c *Config) any { return c.Scraper.Transport.MaxIdleConns }, 50},
+		{"SCRAPER_TRANSPORT_MAX_IDLE_CONNS_PER_HOST", "4", func(c *Config) any { return c.Scraper.Transport.MaxIdleConnsPerHost }, 4},
+		{"SCRAPER_TRANSPORT_MAX_CONNS_PER_HOST", "8", func(c *Config) any { return c.Scraper.Transport.MaxConnsPerHost }, 8},
+		{"SCRAPER_TRANSPORT_IDLE_CONN_TIMEOUT", "30s", func(c *Config) any { return c.Scraper.Transport.IdleConnTimeout }, Duration(30 * time.Second)},
+		{"ARCHIVE_RETENTION", "2160h", func(c *Config) any { return c.Archive.Retention }, Duration(90 * 24 * time.Hour)},
+	}
+
+	for _, tc := range testCases {
+		name := EnvPrefix + tc.name
+		lookup := func(key string) (string, bool) {
+			return tc.value, key == name
+		}
+		cfg := Default()
+		if err := cfg.ApplyEnv(lookup); err != nil {
+			t.Errorf("Error applying %s: %v", name, err)
+			continue
+		}
+		if got := tc.got(cfg); got != tc.want {
+			t.Errorf("%s=%s applied %v; want %v", name, tc.value, got, tc.want)
+		}
+
+		// An invalid value is reported with the name of the variable
+		invalid := func(key string) (string, bool) {
+			return "many", key == name
+		}
+		if err := Default().ApplyEnv(invalid); err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("Expected an error naming %s, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headlines.toml")
+	err := os.WriteFile(path, []byte(`
+[server]
+port = 8181
+
+[[sources]]
+id = "mzamin"
+url = "https://mzamin.com/"
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, func(string) (string, bool) { return "", false })
+	if err != nil {
+		t.Fatalf("Error loading config: %v", err)
+	}
+	if cfg.Server.Port != 8181 {
+		t.Errorf("Expected port 8181, got %d", cfg.Server.Port)
+	}
+	if len(cfg.Sources) != 1 || cfg.Sources[0].ID != "mzamin" {
+		t.Errorf("Expected sources from file to replace the defaults, got %+v", cfg.Sources)
+	}
+}
+
+func TestEnableOnly(t *testing.T) {
+	cfg := Default()
+	if err := cfg.EnableOnly([]string{"dailystarbangla", " prothomalo"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	enabled := cfg.EnabledSources()
+	if len(enabled) != 2 || enabled[0].ID != "dailystarbangla" || enabled[1].ID != "prothomalo" {
+		t.Errorf("Expected dailystarbangla and prothomalo in order, got %+v", enabled)
+	}
+
+	if err := cfg.EnableOnly([]string{"unknown"}); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = 0
+	cfg.Scraper.UserAgent = " "
+	cfg.Scraper.Offline = OfflineConfig{Dir: "pages", HAR: "recording.har"}
+	cfg.Scraper.Transport.Proxy = "ftp://proxy.local"
+	cfg.Sources = append(cfg.Sources, SourceConfig{ID: "mzamin", URL: "mzamin.com"})
+	cfg.Sources[0].Sections = []SectionConfig{{Category: "sports", URL: "/sports"}}
+	cfg.History.Retention = Duration(7 * 24 * time.Hour)
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Expected validation errors")
+	}
+
+	for _, want := range []string{"server.port", "scraper.user_agent", "scraper.offline", "scraper.transport.proxy", "is duplicated", "absolute http(s) URL", "sources[0].sections[0].url", "history.retention"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected validation error to mention %q, got %v", want, err)
+		}
+	}
+}
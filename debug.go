@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/shaharia-lab/headlines/config"
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+// debugReport is the outcome of a traced extraction
+type debugReport struct {
+	Source string                `json:"source"`
+	URL    string                `json:"url"`
+	Events []headline.TraceEvent `json:"events"`
+	Items  []headline.NewsItem   `json:"items"`
+}
+
+// runDebug runs the extraction of a source on a page with tracing, prints every candidate the
+// extraction considered and the resulting items, and returns the exit code
+func runDebug(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("headlines debug", flag.ExitOnError)
+	sourceID := fs.String("source", "", "ID of the source whose extraction is traced")
+	file := fs.String("file", "", "Saved page to extract from instead of fetching it")
+	pageURL := fs.String("url", "", "Page to fetch instead of the source's configured URL")
+	asJSON := fs.Bool("json", false, "Print the trace as JSON")
+	cfg, _, err := parseConfig(fs, args)
+	if err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return exitError
+	}
+	if *sourceID == "" {
+		log.Printf("--source is required")
+		return exitUsage
+	}
+
+	sc := config.SourceConfig{ID: *sourceID}
+	for _, configured := range cfg.Sources {
+		if configured.ID == *sourceID {
+			sc = configured
+		}
+	}
+	if *pageURL != "" {
+		sc.URL = *pageURL
+	}
+
+	httpClient, err := newHTTPClient(cfg.Scraper, cfg.Cache)
+	if err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return exitError
+	}
+	if httpClient, err = sourceHTTPClient(sc, httpClient); err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return exitError
+	}
+	source, err := headline.New(sc.SourceType(), headline.Options{
+		ID:         sc.ID,
+		URL:        sc.URL,
+		HTTPClient: httpClient,
+		Params:     sc.Options,
+	})
+	if err != nil {
+		log.Printf("Invalid source: %v", err)
+		return exitUsage
+	}
+	extractor, ok := source.(headline.TracingExtractor)
+	if !ok {
+		log.Printf("Source %s does not support tracing", sc.ID)
+		return exitError
+	}
+
+	report := debugReport{Source: sc.ID, URL: sc.URL}
+	var page []byte
+	if *file != "" {
+		report.URL = *file
+		if page, err = os.ReadFile(*file); err == nil {
+			page, err = headline.DecodeHTML(page)
+		}
+	} else {
+		if report.URL == "" {
+			report.URL = source.SourceInfo().Homepage
+		}
+		page, err = fetchPage(headline.Chain(httpClient, headline.LoggingMiddleware(log.Default())), report.URL)
+	}
+	if err != nil {
+		log.Printf("Failed to read %s: %v", report.URL, err)
+		return exitError
+	}
+
+	report.Items, err = extractor.ExtractHeadlines(string(page), func(e headline.TraceEvent) {
+		report.Events = append(report.Events, e)
+	})
+	if err != nil {
+		log.Printf("Failed to extract headlines: %v", err)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeDebugReport(stdout, report)
+	}
+	if err != nil {
+		log.Printf("Failed to write trace: %v", err)
+		return exitError
+	}
+	if len(report.Items) == 0 {
+		return exitFailed
+	}
+	return exitOK
+}
+
+// fetchPage fetches a page, failing unless it was found
+func fetchPage(fetcher headline.Fetcher, url string) ([]byte, error) {
+	page, err := fetcher.Fetch(context.Background(), url)
+	if err != nil {
+		return nil, err
+	}
+	if page.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", page.StatusCode)
+	}
+	return page.Body, nil
+}
+
+// writeDebugReport prints a trace for reading in a terminal
+func writeDebugReport(w io.Writer, report debugReport) error {
+	fmt.Fprintf(w, "Source %s, page %s\n\n", report.Source, report.URL)
+
+	matched := 0
+	for _, e := range report.Events {
+		fmt.Fprintf(w, "%-8s %s\n", e.Outcome, e.Path)
+		fmt.Fprintf(w, "         rule: %s\n", e.Rule)
+		if e.Reason != "" {
+			fmt.Fprintf(w, "         reason: %s\n", e.Reason)
+		}
+		if e.Title != "" {
+			fmt.Fprintf(w, "         title: %s\n", singleLine(e.Title))
+		}
+		if e.URL != "" {
+			fmt.Fprintf(w, "         url: %s\n", e.URL)
+		}
+		if e.Outcome == headline.TraceMatched {
+			matched++
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d candidates, %d matched, %d rejected\n", len(report.Events), matched, len(report.Events)-matched)
+	fmt.Fprintf(w, "\nItems (%d):\n", len(report.Items))
+	for _, item := range report.Items {
+		fmt.Fprintf(w, "%3d. [%s] %s\n", item.Rank, item.Prominence, singleLine(item.Title))
+		if _, err := fmt.Fprintf(w, "     %s\n", item.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
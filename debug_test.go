@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunDebug(t *testing.T) {
+	page := filepath.Join(t.TempDir(), "page.html")
+	os.WriteFile(page, []byte(`<html><body>
+		<h1 class="display-3"><a href="/article1">Headline 1</a></h1>
+		<h3><a>Headline 2</a></h3>
+	</body></html>`), 0o644)
+
+	var buf bytes.Buffer
+	if code := runDebug([]string{"--source", "mzamin", "--file", page, "--json"}, &buf); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	var report debugReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Could not parse trace: %v", err)
+	}
+	if len(report.Items) != 1 || len(report.Events) != 2 || report.Events[1].Reason != "missing href" {
+		t.Errorf("Expected a matched and a rejected candidate, got %+v", report)
+	}
+
+	os.WriteFile(page, []byte(`<html></html>`), 0o644)
+	if code := runDebug([]string{"--source", "mzamin", "--file", page}, &buf); code != exitFailed {
+		t.Errorf("Expected exit code %d for a page without headlines, got %d", exitFailed, code)
+	}
+	if code := runDebug([]string{"--source", "unknown", "--file", page}, &buf); code != exitUsage {
+		t.Errorf("Expected exit code %d for an unknown source, got %d", exitUsage, code)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shaharia-lab/headlines/export"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// runExport writes the articles of the history file in an export format and returns the exit code
+func runExport(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("headlines export", flag.ExitOnError)
+	format := fs.String("format", "csv", "Output format: "+strings.Join(export.Formats, ", "))
+	sourceID := fs.String("source", "", "ID of the source to export, all sources when empty")
+	from := fs.String("from", "", "Only export articles seen at or after this RFC 3339 time")
+	to := fs.String("to", "", "Only export articles seen at or before this RFC 3339 time")
+	output := fs.String("output", "", "File to write to instead of stdout")
+	cfg, _, err := parseConfig(fs, args)
+	if err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return exitError
+	}
+	if !validExportFormat(*format) {
+		log.Printf("Unknown format %q, expected one of %s", *format, strings.Join(export.Formats, ", "))
+		return exitUsage
+	}
+	fromTime, err := parseTimeParam("from", *from)
+	if err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	toTime, err := parseTimeParam("to", *to)
+	if err != nil {
+		log.Print(err)
+		return exitUsage
+	}
+	if cfg.History.Path == "" {
+		log.Printf("history.path is not set, there is no history to export")
+		return exitError
+	}
+
+	// The history is only read, as the file may be appended to by a running server
+	store, err := history.Load(cfg.History.Path)
+	if err != nil {
+		log.Printf("Failed to read history: %v", err)
+		return exitError
+	}
+
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Printf("Failed to create output: %v", err)
+			return exitError
+		}
+		defer f.Close()
+		out = f
+	}
+	if err := export.Articles(store, *format, out, *sourceID, fromTime, toTime); err != nil {
+		log.Printf("Failed to export: %v", err)
+		return exitError
+	}
+	return exitOK
+}
+
+func validExportFormat(format string) bool {
+	for _, f := range export.Formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeParam parses an optional RFC 3339 time, an empty value is the zero time
+func parseTimeParam(name, value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q, expected an RFC 3339 time such as 2024-01-01T12:00:00Z", name, value)
+	}
+	return t, nil
+}
+
+// exportHandler streams the articles seen between the from and to parameters as CSV, NDJSON or
+// Parquet, as a file download
+func exportHandler(store *history.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		format := query.Get("format")
+		if format == "" {
+			format = "csv"
+		}
+		if !validExportFormat(format) {
+			http.Error(w, fmt.Sprintf("Unknown format %q, expected one of %s", format, strings.Join(export.Formats, ", ")), http.StatusBadRequest)
+			return
+		}
+		from, err := parseTimeParam("from", query.Get("from"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		to, err := parseTimeParam("to", query.Get("to"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", export.ContentType(format))
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="headlines.%s"`, format))
+		// The status is sent with the first rows, so a later failure can only be logged
+		if err := export.Articles(store, format, w, query.Get("source"), from, to); err != nil {
+			log.Printf("Failed to export: %v", err)
+		}
+	}
+}
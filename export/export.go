@@ -0,0 +1,146 @@
+// Package export writes the articles of the history as CSV, NDJSON or Parquet for analysis
+// in other tools. The rows are written as they are produced, so exports of any size can be
+// streamed.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// Formats are the supported export formats
+var Formats = []string{"csv", "ndjson", "parquet"}
+
+// Columns are the columns of every format, in order. The schema is stable: columns are only
+// ever added at the end.
+var Columns = []string{"source", "id", "title", "url", "category", "rank", "best_rank", "prominence", "first_seen", "last_seen"}
+
+// Row is an exported article
+type Row struct {
+	Source   string `json:"source"`
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	// Rank is the rank the article was last seen at, BestRank the highest it ever had
+	Rank       int       `json:"rank"`
+	BestRank   int       `json:"best_rank"`
+	Prominence string    `json:"prominence"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// NewRow creates the row of an article
+func NewRow(a history.Article) Row {
+	return Row{
+		Source:     a.Source,
+		ID:         a.ID,
+		Title:      a.Title,
+		URL:        a.URL,
+		Category:   a.Category,
+		Rank:       a.Rank,
+		BestRank:   a.BestRank,
+		Prominence: a.Prominence,
+		FirstSeen:  a.FirstSeen.UTC(),
+		LastSeen:   a.LastSeen.UTC(),
+	}
+}
+
+// Writer writes rows in an export format. Close must be called to complete the output.
+type Writer interface {
+	Write(Row) error
+	Close() error
+}
+
+// ContentType returns the media type of a format
+func ContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "ndjson":
+		return "application/x-ndjson"
+	case "parquet":
+		return "application/vnd.apache.parquet"
+	}
+	return "application/octet-stream"
+}
+
+// NewWriter creates a writer of the format to w
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case "csv":
+		return newCSVWriter(w)
+	case "ndjson":
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case "parquet":
+		return newParquetWriter(w)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	cw := &csvWriter{w: csv.NewWriter(w)}
+	// The header is written even if there are no rows
+	if err := cw.w.Write(Columns); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) Write(r Row) error {
+	return cw.w.Write([]string{
+		r.Source,
+		r.ID,
+		r.Title,
+		r.URL,
+		r.Category,
+		strconv.Itoa(r.Rank),
+		strconv.Itoa(r.BestRank),
+		r.Prominence,
+		r.FirstSeen.Format(time.RFC3339),
+		r.LastSeen.Format(time.RFC3339),
+	})
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (nw *ndjsonWriter) Write(r Row) error {
+	return nw.enc.Encode(r)
+}
+
+func (nw *ndjsonWriter) Close() error {
+	return nil
+}
+
+// Articles writes the articles of a source, or of all sources if source is empty, seen between
+// from and to, in the order they were first seen. A zero time leaves that end open.
+func Articles(store *history.Store, format string, w io.Writer, source string, from, to time.Time) error {
+	out, err := NewWriter(format, w)
+	if err != nil {
+		return err
+	}
+	err = store.EachArticle(source, from, to, func(a history.Article) error {
+		return out.Write(NewRow(a))
+	})
+	if err != nil {
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,246 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func testStore(t *testing.T) (*history.Store, time.Time) {
+	store, err := history.Open("", 0)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store.Record(start, []headline.Response{
+		{Source: headline.SourceInfo{ID: "mzamin"}, Headlines: []headline.NewsItem{
+			{Title: "Budget, passed", URL: "https://mzamin.com/1", Category: "politics", Rank: 1, Prominence: headline.ProminenceLead},
+		}},
+	})
+	store.Record(start.Add(time.Hour), []headline.Response{
+		{Source: headline.SourceInfo{ID: "mzamin"}, Headlines: []headline.NewsItem{
+			{Title: "Cricket", URL: "https://mzamin.com/2", Category: "sports", Rank: 1, Prominence: headline.ProminenceLead},
+			{Title: "Budget, passed", URL: "https://mzamin.com/1", Category: "politics", Rank: 2, Prominence: headline.ProminenceTop},
+		}},
+	})
+	return store, start
+}
+
+func TestColumns(t *testing.T) {
+	if len(parquetColumns) != len(Columns) {
+		t.Fatalf("Expected %d Parquet columns, got %d", len(Columns), len(parquetColumns))
+	}
+	for i, c := range parquetColumns {
+		if c.name != Columns[i] {
+			t.Errorf("Expected Parquet column %d to be %s, got %s", i, Columns[i], c.name)
+		}
+	}
+}
+
+func TestArticlesCSV(t *testing.T) {
+	store, start := testStore(t)
+	var out bytes.Buffer
+	if err := Articles(store, "csv", &out, "", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Error reading CSV: %v", err)
+	}
+	if len(records) != 3 || records[0][0] != "source" || records[0][9] != "last_seen" {
+		t.Fatalf("Expected a header and 2 rows, got %v", records)
+	}
+	budget := records[1]
+	if budget[2] != "Budget, passed" || budget[4] != "politics" || budget[5] != "2" || budget[6] != "1" {
+		t.Errorf("Unexpected row %v", budget)
+	}
+	if budget[8] != start.Format(time.RFC3339) || budget[9] != start.Add(time.Hour).Format(time.RFC3339) {
+		t.Errorf("Expected the row to be seen from %s to %s, got %v", start, start.Add(time.Hour), budget)
+	}
+
+	// Only the header is written when nothing matches
+	out.Reset()
+	Articles(store, "csv", &out, "prothomalo", time.Time{}, time.Time{})
+	if records, _ := csv.NewReader(&out).ReadAll(); len(records) != 1 {
+		t.Errorf("Expected only the header, got %v", records)
+	}
+}
+
+func TestArticlesNDJSON(t *testing.T) {
+	store, start := testStore(t)
+	var out bytes.Buffer
+	if err := Articles(store, "ndjson", &out, "mzamin", start.Add(30*time.Minute), time.Time{}); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+
+	var rows []map[string]any
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("Error decoding line %q: %v", scanner.Text(), err)
+		}
+		rows = append(rows, row)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected both articles seen after from, got %v", rows)
+	}
+	for _, column := range Columns {
+		if _, ok := rows[0][column]; !ok {
+			t.Errorf("Expected the row to have column %s, got %v", column, rows[0])
+		}
+	}
+	if rows[1]["title"] != "Cricket" || rows[1]["first_seen"] != "2024-01-01T13:00:00Z" {
+		t.Errorf("Unexpected row %v", rows[1])
+	}
+}
+
+func TestArticlesParquet(t *testing.T) {
+	store, start := testStore(t)
+	var out bytes.Buffer
+	if err := Articles(store, "parquet", &out, "", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+
+	data := out.Bytes()
+	if !bytes.HasPrefix(data, parquetMagic) || !bytes.HasSuffix(data, parquetMagic) {
+		t.Fatal("Expected the file to start and end with PAR1")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := readThrift(t, bytes.NewReader(data[len(data)-8-footerLen:len(data)-8]))
+
+	if rows := footer[3]; rows != int64(2) {
+		t.Errorf("Expected 2 rows, got %v", rows)
+	}
+	schema := footer[2].([]any)
+	if len(schema) != len(Columns)+1 || schema[3].(map[int16]any)[4] != "title" {
+		t.Errorf("Unexpected schema %v", schema)
+	}
+
+	// The title column chunk holds one PLAIN encoded value per row
+	rowGroups := footer[4].([]any)
+	chunk := rowGroups[0].(map[int16]any)[1].([]any)[2].(map[int16]any)
+	offset := chunk[3].(map[int16]any)[9].(int64)
+	page := bytes.NewReader(data[offset:])
+	header := readThrift(t, page)
+	if header[5].(map[int16]any)[1] != int64(2) {
+		t.Errorf("Expected a page of 2 values, got %v", header)
+	}
+	var titles []string
+	for i := 0; i < 2; i++ {
+		var n uint32
+		binary.Read(page, binary.LittleEndian, &n)
+		title := make([]byte, n)
+		page.Read(title)
+		titles = append(titles, string(title))
+	}
+	if titles[0] != "Budget, passed" || titles[1] != "Cricket" {
+		t.Errorf("Unexpected titles %v", titles)
+	}
+
+	firstSeen := rowGroups[0].(map[int16]any)[1].([]any)[8].(map[int16]any)[3].(map[int16]any)[9].(int64)
+	page = bytes.NewReader(data[firstSeen:])
+	readThrift(t, page)
+	var millis int64
+	binary.Read(page, binary.LittleEndian, &millis)
+	if millis != start.UnixMilli() {
+		t.Errorf("Expected the first article to be first seen at %d, got %d", start.UnixMilli(), millis)
+	}
+}
+
+// TestArticlesGolden compares the exports to the golden files in testdata, which
+// testdata/verify_parquet.py reads with pyarrow and DuckDB to check that the Parquet file is
+// readable by other tools and holds the same rows as the NDJSON file. Run with -update to
+// write them after changing the format, and run the script on the new files.
+func TestArticlesGolden(t *testing.T) {
+	store, _ := testStore(t)
+	for _, format := range []string{"ndjson", "parquet"} {
+		var out bytes.Buffer
+		if err := Articles(store, format, &out, "", time.Time{}, time.Time{}); err != nil {
+			t.Fatalf("Error exporting %s: %v", format, err)
+		}
+		golden := filepath.Join("testdata", "articles."+format)
+		if *update {
+			if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+				t.Fatalf("Error updating %s: %v", golden, err)
+			}
+		}
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", golden, err)
+		}
+		if !bytes.Equal(out.Bytes(), want) {
+			t.Errorf("The %s export differs from %s, run the tests with -update if the change is intended", format, golden)
+		}
+	}
+}
+
+// readThrift decodes a struct of the Thrift compact protocol as a map of field IDs to values
+func readThrift(t *testing.T, r *bytes.Reader) map[int16]any {
+	t.Helper()
+	fields := make(map[int16]any)
+	var last int16
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			t.Fatalf("Truncated struct: %v", err)
+		}
+		if b == 0 {
+			return fields
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			id = int16(readZigzag(t, r))
+		}
+		last = id
+		fields[id] = readThriftValue(t, r, b&0x0f)
+	}
+}
+
+func readThriftValue(t *testing.T, r *bytes.Reader, typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return readZigzag(t, r)
+	case thriftBinary:
+		n, _ := binary.ReadUvarint(r)
+		s := make([]byte, n)
+		r.Read(s)
+		return string(s)
+	case thriftList:
+		b, _ := r.ReadByte()
+		n := uint64(b >> 4)
+		if n == 15 {
+			n, _ = binary.ReadUvarint(r)
+		}
+		list := make([]any, n)
+		for i := range list {
+			list[i] = readThriftValue(t, r, b&0x0f)
+		}
+		return list
+	case thriftStruct:
+		return readThrift(t, r)
+	}
+	t.Fatalf("Unexpected Thrift type %d", typ)
+	return nil
+}
+
+func readZigzag(t *testing.T, r *bytes.Reader) int64 {
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		t.Fatalf("Invalid varint: %v", err)
+	}
+	return int64(v>>1) ^ -int64(v&1)
+}
@@ -0,0 +1,311 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Parquet is written without a third-party library: every column is required, PLAIN encoded
+// and uncompressed, with a single data page per column chunk. The metadata is serialized with
+// the Thrift compact protocol as the format specifies. The output is compared to
+// testdata/articles.parquet, which CI reads with pyarrow and DuckDB.
+
+// Parquet physical types, converted types and encodings used by the writer
+const (
+	parquetInt32     = 1
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	convertedNone            = -1
+	convertedUTF8            = 0
+	convertedTimestampMillis = 9
+
+	encodingPlain = 0
+	encodingRLE   = 3
+)
+
+// parquetRowGroupRows bounds the rows buffered in memory before a row group is written
+const parquetRowGroupRows = 50000
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn describes a column of the Parquet schema and encodes its value of a row
+type parquetColumn struct {
+	name      string
+	typ       int32
+	converted int32
+	encode    func(*bytes.Buffer, Row)
+}
+
+// parquetColumns are in the order of Columns
+var parquetColumns = []parquetColumn{
+	{"source", parquetByteArray, convertedUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.Source) }},
+	{"id", parquetByteArray, convertedUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.ID) }},
+	{"title", parquetByteArray, convertedUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.Title) }},
+	{"url", parquetByteArray, convertedUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.URL) }},
+	{"category", parquetByteArray, convertedUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.Category) }},
+	{"rank", parquetInt32, convertedNone, func(b *bytes.Buffer, r Row) { plainInt32(b, int32(r.Rank)) }},
+	{"best_rank", parquetInt32, convertedNone, func(b *bytes.Buffer, r Row) { plainInt32(b, int32(r.BestRank)) }},
+	{"prominence", parquetByteArray, convertedUTF8, func(b *bytes.Buffer, r Row) { plainString(b, r.Prominence) }},
+	{"first_seen", parquetInt64, convertedTimestampMillis, func(b *bytes.Buffer, r Row) { plainInt64(b, r.FirstSeen.UnixMilli()) }},
+	{"last_seen", parquetInt64, convertedTimestampMillis, func(b *bytes.Buffer, r Row) { plainInt64(b, r.LastSeen.UnixMilli()) }},
+}
+
+func plainString(b *bytes.Buffer, s string) {
+	b.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(s))))
+	b.WriteString(s)
+}
+
+func plainInt32(b *bytes.Buffer, v int32) {
+	b.Write(binary.LittleEndian.AppendUint32(nil, uint32(v)))
+}
+
+func plainInt64(b *bytes.Buffer, v int64) {
+	b.Write(binary.LittleEndian.AppendUint64(nil, uint64(v)))
+}
+
+type columnChunk struct {
+	offset int64
+	size   int64
+}
+
+type rowGroup struct {
+	rows   int64
+	chunks []columnChunk
+}
+
+type parquetWriter struct {
+	w      io.Writer
+	offset int64
+	// pages holds the encoded values of each column of the current row group
+	pages     []bytes.Buffer
+	rows      int64
+	rowGroups []rowGroup
+}
+
+func newParquetWriter(w io.Writer) (*parquetWriter, error) {
+	pw := &parquetWriter{w: w, pages: make([]bytes.Buffer, len(parquetColumns))}
+	if err := pw.write(parquetMagic); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+func (pw *parquetWriter) write(b []byte) error {
+	n, err := pw.w.Write(b)
+	pw.offset += int64(n)
+	return err
+}
+
+func (pw *parquetWriter) Write(r Row) error {
+	for i, c := range parquetColumns {
+		c.encode(&pw.pages[i], r)
+	}
+	pw.rows++
+	if pw.rows == parquetRowGroupRows {
+		return pw.flush()
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group
+func (pw *parquetWriter) flush() error {
+	if pw.rows == 0 {
+		return nil
+	}
+	rg := rowGroup{rows: pw.rows}
+	for i := range pw.pages {
+		data := pw.pages[i].Bytes()
+		header := pageHeader(pw.rows, len(data))
+		chunk := columnChunk{offset: pw.offset, size: int64(len(header) + len(data))}
+		if err := pw.write(header); err != nil {
+			return err
+		}
+		if err := pw.write(data); err != nil {
+			return err
+		}
+		pw.pages[i].Reset()
+		rg.chunks = append(rg.chunks, chunk)
+	}
+	pw.rowGroups = append(pw.rowGroups, rg)
+	pw.rows = 0
+	return nil
+}
+
+func (pw *parquetWriter) Close() error {
+	if err := pw.flush(); err != nil {
+		return err
+	}
+	footer := pw.fileMetaData()
+	if err := pw.write(footer); err != nil {
+		return err
+	}
+	if err := pw.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	return pw.write(parquetMagic)
+}
+
+// pageHeader serializes the PageHeader of a data page. Required columns have no repetition or
+// definition levels, so the page only holds the values.
+func pageHeader(rows int64, size int) []byte {
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 0) // DATA_PAGE
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.structField(5)
+	t.i32(1, int32(rows))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.end()
+	t.end()
+	return t.buf.Bytes()
+}
+
+// fileMetaData serializes the FileMetaData of the footer
+func (pw *parquetWriter) fileMetaData() []byte {
+	var t thriftWriter
+	t.begin()
+	t.i32(1, 1)
+
+	t.list(2, thriftStruct, len(parquetColumns)+1)
+	t.begin()
+	t.str(4, "schema")
+	t.i32(5, int32(len(parquetColumns)))
+	t.end()
+	for _, c := range parquetColumns {
+		t.begin()
+		t.i32(1, c.typ)
+		t.i32(3, 0) // REQUIRED
+		t.str(4, c.name)
+		if c.converted != convertedNone {
+			t.i32(6, c.converted)
+		}
+		t.end()
+	}
+
+	var rows int64
+	for _, rg := range pw.rowGroups {
+		rows += rg.rows
+	}
+	t.i64(3, rows)
+
+	t.list(4, thriftStruct, len(pw.rowGroups))
+	for _, rg := range pw.rowGroups {
+		t.begin()
+		t.list(1, thriftStruct, len(rg.chunks))
+		var size int64
+		for i, chunk := range rg.chunks {
+			c := parquetColumns[i]
+			size += chunk.size
+			t.begin()
+			t.i64(2, chunk.offset)
+			t.structField(3)
+			t.i32(1, c.typ)
+			t.list(2, thriftI32, 1)
+			t.varint(zigzag(encodingPlain))
+			t.list(3, thriftBinary, 1)
+			t.rawString(c.name)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, rg.rows)
+			t.i64(6, chunk.size)
+			t.i64(7, chunk.size)
+			t.i64(9, chunk.offset)
+			t.end()
+			t.end()
+		}
+		t.i64(2, size)
+		t.i64(3, rg.rows)
+		t.end()
+	}
+
+	t.str(6, "headlines")
+	t.end()
+	return t.buf.Bytes()
+}
+
+// Thrift compact protocol types
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter serializes structs with the Thrift compact protocol
+type thriftWriter struct {
+	buf bytes.Buffer
+	// last holds the last field ID of each open struct, as field IDs are delta encoded
+	last []int16
+}
+
+// begin starts a struct, either the top-level one or an element of a list
+func (t *thriftWriter) begin() {
+	t.last = append(t.last, 0)
+}
+
+// end ends the innermost struct
+func (t *thriftWriter) end() {
+	t.buf.WriteByte(0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	*last = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.rawString(s)
+}
+
+func (t *thriftWriter) rawString(s string) {
+	t.varint(uint64(len(s)))
+	t.buf.WriteString(s)
+}
+
+// structField starts a struct valued field, which is ended with end
+func (t *thriftWriter) structField(id int16) {
+	t.field(id, thriftStruct)
+	t.begin()
+}
+
+// list starts a list field of n elements, which are written directly after it
+func (t *thriftWriter) list(id int16, elem byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	t.buf.WriteByte(0xf0 | elem)
+	t.varint(uint64(n))
+}
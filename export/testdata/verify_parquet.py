@@ -0,0 +1,59 @@
+"""Checks that articles.parquet is read by pyarrow and DuckDB as the rows of articles.ndjson.
+
+    pip install pyarrow duckdb
+    python export/testdata/verify_parquet.py
+"""
+
+import datetime
+import json
+import pathlib
+import sys
+
+import duckdb
+import pyarrow.parquet as pq
+
+testdata = pathlib.Path(__file__).parent
+parquet = testdata / "articles.parquet"
+expected = [json.loads(line) for line in (testdata / "articles.ndjson").read_text().splitlines()]
+
+types = {
+    "source": "string",
+    "id": "string",
+    "title": "string",
+    "url": "string",
+    "category": "string",
+    "rank": "int32",
+    "best_rank": "int32",
+    "prominence": "string",
+    "first_seen": "timestamp[ms, tz=UTC]",
+    "last_seen": "timestamp[ms, tz=UTC]",
+}
+
+
+def normalize(row):
+    """Formats the timestamps of a row as in the NDJSON export"""
+    out = {}
+    for name, value in row.items():
+        if isinstance(value, datetime.datetime):
+            if value.tzinfo is None:
+                value = value.replace(tzinfo=datetime.timezone.utc)
+            value = value.astimezone(datetime.timezone.utc).strftime("%Y-%m-%dT%H:%M:%SZ")
+        out[name] = value
+    return out
+
+
+def check(reader, rows):
+    rows = [normalize(row) for row in rows]
+    if rows != expected:
+        sys.exit(f"{reader} read {rows}, expected {expected}")
+    print(f"{reader}: {len(rows)} rows as expected")
+
+
+table = pq.read_table(parquet)
+schema = {field.name: str(field.type) for field in table.schema}
+if schema != types:
+    sys.exit(f"pyarrow read the schema {schema}, expected {types}")
+check("pyarrow", table.to_pylist())
+
+result = duckdb.sql(f"SELECT * FROM read_parquet('{parquet}')")
+check("duckdb", [dict(zip(result.columns, row)) for row in result.fetchall()])
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+func TestRunExport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := history.Open(path, 0)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	store.Record(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), []headline.Response{
+		{Source: headline.SourceInfo{ID: "mzamin"}, Headlines: []headline.NewsItem{{Title: "Lead", URL: "https://mzamin.com/1", Rank: 1}}},
+	})
+	store.Close()
+	t.Setenv("HEADLINES_HISTORY_PATH", path)
+
+	var buf bytes.Buffer
+	if code := runExport([]string{"--format", "ndjson", "--from", "2024-01-01T00:00:00Z"}, &buf); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if !strings.Contains(buf.String(), `"title":"Lead"`) {
+		t.Errorf("Expected the article to be exported, got %q", buf.String())
+	}
+
+	buf.Reset()
+	runExport([]string{"--to", "2024-01-01T00:00:00Z"}, &buf)
+	if lines := strings.Count(buf.String(), "\n"); lines != 1 {
+		t.Errorf("Expected only the CSV header, got %q", buf.String())
+	}
+
+	if code := runExport([]string{"--format", "xml"}, &buf); code != exitUsage {
+		t.Errorf("Expected exit code %d for an unknown format, got %d", exitUsage, code)
+	}
+	if code := runExport([]string{"--from", "yesterday"}, &buf); code != exitUsage {
+		t.Errorf("Expected exit code %d for an invalid time, got %d", exitUsage, code)
+	}
+}
+
+func TestRunExportLiveHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := history.Open(path, 0)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	defer store.Close()
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	page := []headline.Response{
+		{Source: headline.SourceInfo{ID: "mzamin"}, Headlines: []headline.NewsItem{{Title: "Lead", URL: "https://mzamin.com/1", Rank: 1}}},
+	}
+	// Repeated sightings of an unchanged page are what Open compacts
+	for i := 0; i < 3; i++ {
+		store.Record(at.Add(time.Duration(i)*time.Minute), page)
+	}
+	t.Setenv("HEADLINES_HISTORY_PATH", path)
+
+	var buf bytes.Buffer
+	if code := runExport([]string{"--format", "ndjson"}, &buf); code != exitOK {
+		t.Fatalf("Expected exit code %d, got %d", exitOK, code)
+	}
+
+	// The server keeps appending to the file after the export
+	store.Record(at.Add(time.Hour), []headline.Response{
+		{Source: headline.SourceInfo{ID: "mzamin"}, Headlines: []headline.NewsItem{{Title: "Later", URL: "https://mzamin.com/2", Rank: 1}}},
+	})
+	store.Close()
+
+	reopened, err := history.Open(path, 0)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	if _, ok := reopened.Article(history.ArticleID("https://mzamin.com/2")); !ok {
+		t.Error("Expected the observation recorded after the export to be persisted")
+	}
+	if a, ok := reopened.Article(history.ArticleID("https://mzamin.com/1")); !ok || !a.LastSeen.Equal(at.Add(2*time.Minute)) {
+		t.Errorf("Expected the sightings before the export to be persisted, got %+v", a)
+	}
+}
+
+func TestExportHandler(t *testing.T) {
+	store, _ := history.Open("", 0)
+	store.Record(time.Now(), []headline.Response{
+		{Source: headline.SourceInfo{ID: "mzamin"}, Headlines: []headline.NewsItem{{Title: "Lead", URL: "https://mzamin.com/1", Rank: 1}}},
+	})
+	handler := exportHandler(store)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/api/export?format=csv&source=mzamin", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" {
+		t.Fatalf("Expected a CSV response, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "headlines.csv") || !strings.Contains(rec.Body.String(), "mzamin,") {
+		t.Errorf("Expected the article as a CSV download, got %q", rec.Body.String())
+	}
+
+	for _, query := range []string{"format=xml", "from=yesterday"} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/api/export?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected %s to be rejected, got %d", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+// Exit codes of the fetch command
+const (
+	exitOK = 0
+	// exitError is returned for an invalid configuration or when the output cannot be written
+	exitError = 1
+	// exitUsage is returned for invalid flags, as by the flag package
+	exitUsage = 2
+	// exitPartial is returned when some of the sources failed
+	exitPartial = 3
+	// exitFailed is returned when all of the sources failed
+	exitFailed = 4
+)
+
+// outputFormats are the formats the fetch command can print the headlines in
+var outputFormats = []string{"json", "ndjson", "csv", "table", "markdown"}
+
+// runFetch fetches the headlines of the enabled sources once, prints them to stdout and returns the exit code
+func runFetch(args []string, stdout io.Writer) int {
+	fs := flag.NewFlagSet("headlines fetch", flag.ExitOnError)
+	sourceIDs := fs.String("source", "", "Comma separated list of source IDs to fetch instead of the enabled sources")
+	format := fs.String("format", "json", "Output format: "+strings.Join(outputFormats, ", "))
+	cfg, _, err := parseConfig(fs, args)
+	if err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return exitError
+	}
+	if !validFormat(*format) {
+		log.Printf("Unknown format %q, expected one of %s", *format, strings.Join(outputFormats, ", "))
+		return exitUsage
+	}
+	if *sourceIDs != "" {
+		if err := cfg.EnableOnly(strings.Split(*sourceIDs, ",")); err != nil {
+			log.Printf("Invalid source: %v", err)
+			return exitUsage
+		}
+	}
+
+	httpClient, err := newHTTPClient(cfg.Scraper, cfg.Cache)
+	if err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return exitError
+	}
+	var sources []headline.NewsClient
+	for _, sc := range cfg.EnabledSources() {
+		source, err := newSource(sc, httpClient, cfg.Scraper.CircuitBreaker, nil)
+		if err != nil {
+			log.Printf("Invalid configuration: %v", err)
+			return exitError
+		}
+		sources = append(sources, source)
+	}
+
+	// An interrupt cancels the fetches instead of waiting for their retries
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	responses := headline.NewAggregator(func() []headline.NewsClient { return sources }, headline.CachePolicy{}).GetHeadlines(ctx)
+	if err := writeHeadlines(stdout, *format, responses); err != nil {
+		log.Printf("Failed to write headlines: %v", err)
+		return exitError
+	}
+	return fetchExitCode(responses)
+}
+
+func validFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchExitCode returns the exit code for the outcome of a fetch
+func fetchExitCode(responses []headline.Response) int {
+	failed := 0
+	for _, resp := range responses {
+		if resp.Error != nil {
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return exitOK
+	case failed == len(responses):
+		return exitFailed
+	default:
+		return exitPartial
+	}
+}
+
+// headlineRecord is a headline with its source, as printed one per line or row
+type headlineRecord struct {
+	Source     string `json:"source"`
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	Category   string `json:"category,omitempty"`
+	Rank       int    `json:"rank,omitempty"`
+	Prominence string `json:"prominence,omitempty"`
+}
+
+func headlineRecords(responses []headline.Response) []headlineRecord {
+	var records []headlineRecord
+	for _, resp := range responses {
+		source := resp.Source.ID
+		if source == "" {
+			source = resp.Source.Name
+		}
+		for _, item := range resp.Headlines {
+			records = append(records, headlineRecord{
+				Source:     source,
+				Title:      item.Title,
+				URL:        item.URL,
+				Category:   item.Category,
+				Rank:       item.Rank,
+				Prominence: item.Prominence,
+			})
+		}
+	}
+	return records
+}
+
+// writeHeadlines prints the responses in the given format. The json format is the response of
+// /api/headlines, including the errors of failed sources. The other formats only list the headlines.
+func writeHeadlines(w io.Writer, format string, responses []headline.Response) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(responses)
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, record := range headlineRecords(responses) {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"source", "rank", "title", "url", "category", "prominence"})
+		for _, r := range headlineRecords(responses) {
+			cw.Write([]string{r.Source, strconv.Itoa(r.Rank), r.Title, r.URL, r.Category, r.Prominence})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SOURCE\tRANK\tTITLE\tURL")
+		for _, r := range headlineRecords(responses) {
+			fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", r.Source, r.Rank, singleLine(r.Title), r.URL)
+		}
+		return tw.Flush()
+	case "markdown":
+		return writeMarkdown(w, responses)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func writeMarkdown(w io.Writer, responses []headline.Response) error {
+	escaper := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`)
+	for i, resp := range responses {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "## %s\n\n", singleLine(resp.Source.Name))
+		if resp.Error != nil {
+			fmt.Fprintf(w, "_Failed: %s_\n", singleLine(resp.Error.Message))
+		}
+		for j, item := range resp.Headlines {
+			if _, err := fmt.Fprintf(w, "%d. [%s](<%s>)\n", j+1, escaper.Replace(singleLine(item.Title)), item.URL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// singleLine collapses the whitespace of a text, including line breaks and tabs, so that it
+// fits on a line of a table
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
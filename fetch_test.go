@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+func testResponses() []headline.Response {
+	return []headline.Response{
+		{
+			Source: headline.SourceInfo{ID: "mzamin", Name: "Manab Zamin"},
+			Headlines: []headline.NewsItem{
+				{Title: "Test [1]", URL: "http://test1.com", Rank: 1, Prominence: headline.ProminenceLead},
+				{Title: "Test,\n2", URL: "http://test2.com", Rank: 2, Category: headline.CategorySports},
+			},
+		},
+		{
+			Source: headline.SourceInfo{ID: "dailystar", Name: "The Daily Star"},
+			Error:  &headline.SourceError{Type: headline.ErrorTypeFetchFailed, Message: "timeout"},
+		},
+	}
+}
+
+func TestWriteHeadlines(t *testing.T) {
+	testCases := []struct {
+		format   string
+		expected string
+	}{
+		{"ndjson", `{"source":"mzamin","title":"Test [1]","url":"http://test1.com","rank":1,"prominence":"lead"}
+{"source":"mzamin","title":"Test,\n2","url":"http://test2.com","category":"sports","rank":2}
+`},
+		{"csv", `source,rank,title,url,category,prominence
+mzamin,1,Test [1],http://test1.com,,lead
+mzamin,2,"Test,
+2",http://test2.com,sports,
+`},
+		{"table", `SOURCE  RANK  TITLE     URL
+mzamin  1     Test [1]  http://test1.com
+mzamin  2     Test, 2   http://test2.com
+`},
+		{"markdown", `## Manab Zamin
+
+1. [Test \[1\]](<http://test1.com>)
+2. [Test, 2](<http://test2.com>)
+
+## The Daily Star
+
+_Failed: timeout_
+`},
+	}
+
+	for _, tc := range testCases {
+		var buf bytes.Buffer
+		if err := writeHeadlines(&buf, tc.format, testResponses()); err != nil {
+			t.Fatalf("writeHeadlines(%s) returned error: %v", tc.format, err)
+		}
+		if buf.String() != tc.expected {
+			t.Errorf("writeHeadlines(%s) =\n%s\nwant\n%s", tc.format, buf.String(), tc.expected)
+		}
+	}
+
+	// The json format keeps the errors of the failed sources
+	var buf bytes.Buffer
+	if err := writeHeadlines(&buf, "json", testResponses()); err != nil {
+		t.Fatalf("writeHeadlines(json) returned error: %v", err)
+	}
+	var responses []headline.Response
+	if err := json.Unmarshal(buf.Bytes(), &responses); err != nil {
+		t.Fatalf("Could not parse json output: %v", err)
+	}
+	if len(responses) != 2 || responses[1].Error == nil {
+		t.Errorf("Expected the failed source in the json output, got %+v", responses)
+	}
+
+	if err := writeHeadlines(&buf, "xml", testResponses()); err == nil || !strings.Contains(err.Error(), "xml") {
+		t.Errorf("Expected an error for an unknown format, got %v", err)
+	}
+}
+
+func TestFetchExitCode(t *testing.T) {
+	responses := testResponses()
+	if code := fetchExitCode(responses[:1]); code != exitOK {
+		t.Errorf("Expected exit code %d, got %d", exitOK, code)
+	}
+	if code := fetchExitCode(responses); code != exitPartial {
+		t.Errorf("Expected exit code %d, got %d", exitPartial, code)
+	}
+	if code := fetchExitCode(responses[1:]); code != exitFailed {
+		t.Errorf("Expected exit code %d, got %d", exitFailed, code)
+	}
+}
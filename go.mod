@@ -8,13 +8,17 @@ require (
 )
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/go-chi/cors v1.2.1
+	github.com/graphql-go/graphql v0.8.1
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/sync v0.7.0
+	golang.org/x/text v0.16.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/stretchr/objx v0.5.2 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
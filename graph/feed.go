@@ -0,0 +1,97 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// feedBuffer is the number of headlines queued for a subscriber. Headlines are dropped for a
+// subscriber that falls further behind rather than delaying the refresh.
+const feedBuffer = 64
+
+// Feed publishes the headlines appearing on the front pages to the subscriptions
+type Feed struct {
+	mu sync.Mutex
+	// onPage holds the IDs of the articles on each source's page at the last refresh
+	onPage      map[string]map[string]bool
+	subscribers map[chan newsItem]struct{}
+}
+
+// NewFeed creates a feed without subscribers
+func NewFeed() *Feed {
+	return &Feed{
+		onPage:      make(map[string]map[string]bool),
+		subscribers: make(map[chan newsItem]struct{}),
+	}
+}
+
+// Publish sends the headlines that were not on their front page at the previous refresh to the
+// subscribers. The headlines of a source's first refresh are its initial page and are not
+// published. Failed and stale responses are skipped as they do not reflect the current page.
+func (f *Feed) Publish(responses []headline.Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, resp := range responses {
+		if !resp.Current() {
+			continue
+		}
+		previous, known := f.onPage[resp.Source.ID]
+		current := make(map[string]bool, len(resp.Headlines))
+		for _, item := range resp.Headlines {
+			id := history.ArticleID(item.URL)
+			if known && !previous[id] && !current[id] {
+				f.send(newNewsItem(resp.Source.ID, item))
+			}
+			current[id] = true
+		}
+		f.onPage[resp.Source.ID] = current
+	}
+}
+
+func (f *Feed) send(item newsItem) {
+	for ch := range f.subscribers {
+		select {
+		case ch <- item:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel of the published headlines that match, until ctx is done. The
+// channel type is the one graphql-go expects from a subscription resolver.
+func (f *Feed) Subscribe(ctx context.Context, match func(newsItem) bool) chan any {
+	ch := make(chan newsItem, feedBuffer)
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	out := make(chan any)
+	go func() {
+		defer close(out)
+		defer func() {
+			f.mu.Lock()
+			delete(f.subscribers, ch)
+			f.mu.Unlock()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case item := <-ch:
+				if !match(item) {
+					continue
+				}
+				select {
+				case out <- item:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,232 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+)
+
+// keepAliveInterval is how often a comment is sent on an idle subscription so that proxies do
+// not close the connection
+var keepAliveInterval = 30 * time.Second
+
+// Limits of a request, so that a single query cannot exhaust the server
+const (
+	// maxBodySize is the size of the body of a POST
+	maxBodySize = 1 << 20
+	// maxDepth is how deeply the fields of a query are nested
+	maxDepth = 10
+	// maxComplexity is the number of fields of a query, counting those of a fragment each time it is spread
+	maxComplexity = 250
+)
+
+// request is a GraphQL request, sent as JSON in the body of a POST or as the query parameters of a GET
+type request struct {
+	Query         string         `json:"query"`
+	Variables     map[string]any `json:"variables"`
+	OperationName string         `json:"operationName"`
+}
+
+// Handler serves GraphQL queries over GET and POST. Subscriptions are served as server-sent
+// events to clients accepting text/event-stream: each result is a next event and the stream
+// ends with a complete event. Queries are rejected when they exceed the limits of depth,
+// complexity or body size, and the headlines are fetched at most once per query.
+func Handler(schema graphql.Schema) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		req, err := parseRequest(r)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("The request body is larger than %d bytes", maxBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Invalid documents are not checked, so that their errors are reported by the regular execution
+		var op *ast.OperationDefinition
+		if doc, err := parser.Parse(parser.ParseParams{Source: req.Query}); err == nil {
+			op = operation(doc, req.OperationName)
+			if err := checkLimits(doc, op); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		params := graphql.Params{
+			Schema:         schema,
+			RequestString:  req.Query,
+			VariableValues: req.Variables,
+			OperationName:  req.OperationName,
+			Context:        r.Context(),
+		}
+		if op != nil && op.Operation == ast.OperationTypeSubscription {
+			if !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+				http.Error(w, "Subscriptions are served as server-sent events, send Accept: text/event-stream", http.StatusNotAcceptable)
+				return
+			}
+			serveSubscription(w, r, params)
+			return
+		}
+
+		params.Context = withRequestHeadlines(params.Context)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graphql.Do(params))
+	}
+}
+
+func parseRequest(r *http.Request) (request, error) {
+	var req request
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return req, fmt.Errorf("invalid variables: %v", err)
+			}
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return req, fmt.Errorf("invalid request body, expected JSON with a query: %w", err)
+		}
+	}
+	if req.Query == "" {
+		return req, fmt.Errorf("missing query")
+	}
+	return req, nil
+}
+
+// operation returns the operation of the document selected by its name, nil if there is none
+func operation(doc *ast.Document, name string) *ast.OperationDefinition {
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			return op
+		}
+	}
+	return nil
+}
+
+// checkLimits returns an error if the operation nests its fields deeper than maxDepth or
+// selects more than maxComplexity fields
+func checkLimits(doc *ast.Document, op *ast.OperationDefinition) error {
+	if op == nil {
+		return nil
+	}
+	fragments := make(map[string]*ast.FragmentDefinition)
+	for _, def := range doc.Definitions {
+		if fragment, ok := def.(*ast.FragmentDefinition); ok && fragment.Name != nil {
+			fragments[fragment.Name.Value] = fragment
+		}
+	}
+	c := &complexity{fragments: fragments, spreading: make(map[string]bool)}
+	return c.walk(op.SelectionSet, 1)
+}
+
+// complexity counts the fields of an operation
+type complexity struct {
+	fragments map[string]*ast.FragmentDefinition
+	// spreading holds the fragments being walked, so that a cycle, which the validation of the
+	// query rejects, does not recurse forever
+	spreading map[string]bool
+	fields    int
+}
+
+func (c *complexity) walk(set *ast.SelectionSet, depth int) error {
+	if set == nil {
+		return nil
+	}
+	for _, selection := range set.Selections {
+		switch s := selection.(type) {
+		case *ast.Field:
+			if depth > maxDepth {
+				return fmt.Errorf("the query is nested deeper than %d fields", maxDepth)
+			}
+			if c.fields++; c.fields > maxComplexity {
+				return fmt.Errorf("the query selects more than %d fields", maxComplexity)
+			}
+			if err := c.walk(s.SelectionSet, depth+1); err != nil {
+				return err
+			}
+		case *ast.InlineFragment:
+			if err := c.walk(s.SelectionSet, depth); err != nil {
+				return err
+			}
+		case *ast.FragmentSpread:
+			fragment, ok := c.fragments[s.Name.Value]
+			if !ok || c.spreading[s.Name.Value] {
+				continue
+			}
+			c.spreading[s.Name.Value] = true
+			err := c.walk(fragment.SelectionSet, depth)
+			delete(c.spreading, s.Name.Value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func serveSubscription(w http.ResponseWriter, r *http.Request, params graphql.Params) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	results := graphql.Subscribe(params)
+	// The results are drained once the client is gone, so that the subscription can end
+	defer func() {
+		go func() {
+			for range results {
+			}
+		}()
+	}()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case result, ok := <-results:
+			if !ok {
+				fmt.Fprint(w, "event: complete\ndata:\n\n")
+				flusher.Flush()
+				return
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: next\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package graph
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+func TestHandler(t *testing.T) {
+	schema, err := NewSchema(testData(t))
+	if err != nil {
+		t.Fatalf("Error creating schema: %v", err)
+	}
+	handler := Handler(schema)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`query Names($id: ID!) { source(id: $id) { name } }`)+"&variables="+url.QueryEscape(`{"id":"mzamin"}`), nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"data":{"source":{"name":"Manab Zamin"}}}`+"\n" {
+		t.Errorf("Unexpected GET response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ headlines(limit: 1) { title } }"}`)))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"data":{"headlines":[{"title":"Budget passed"}]}}`+"\n" {
+		t.Errorf("Unexpected POST response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected a request without query to be rejected, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`subscription { headlineAdded { title } }`), nil))
+	if rec.Code != http.StatusNotAcceptable {
+		t.Errorf("Expected a subscription without event stream to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestHandlerSubscription(t *testing.T) {
+	data := testData(t)
+	schema, err := NewSchema(data)
+	if err != nil {
+		t.Fatalf("Error creating schema: %v", err)
+	}
+	server := httptest.NewServer(Handler(schema))
+	defer server.Close()
+
+	page := func(items ...headline.NewsItem) []headline.Response {
+		return []headline.Response{{Source: headline.SourceInfo{ID: "mzamin"}, Headlines: items}}
+	}
+	lead := headline.NewsItem{Title: "Lead", URL: "https://mzamin.com/1", Category: "politics"}
+	data.Feed.Publish(page(lead))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	query := url.QueryEscape(`subscription { headlineAdded(source: "mzamin", category: "sports") { title source { id } } }`)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?query="+query, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error subscribing: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", resp.Header.Get("Content-Type"))
+	}
+
+	// The subscription is registered asynchronously, so the headlines are published until one arrives
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if payload, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				select {
+				case events <- payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case event := <-events:
+			if event != `{"data":{"headlineAdded":{"source":{"id":"mzamin"},"title":"Cricket"}}}` {
+				t.Errorf("Unexpected event %s", event)
+			}
+			return
+		case <-ticker.C:
+			// Only the sports headline new on the page matches
+			cricket := headline.NewsItem{Title: "Cricket", URL: "https://mzamin.com/cricket/" + string(rune('a'+i%26)), Category: "sports"}
+			data.Feed.Publish(page(lead, headline.NewsItem{Title: "Other", URL: "https://mzamin.com/other/" + string(rune('a'+i%26)), Category: "politics"}, cricket))
+		case <-ctx.Done():
+			t.Fatal("Timed out waiting for the headline")
+		}
+	}
+}
+
+func TestFeedPublish(t *testing.T) {
+	feed := NewFeed()
+	ctx, cancel := context.WithCancel(context.Background())
+	items := feed.Subscribe(ctx, func(newsItem) bool { return true })
+
+	first := headline.NewsItem{Title: "First", URL: "https://test.com/1"}
+	second := headline.NewsItem{Title: "Second", URL: "https://test.com/2"}
+	page := func(items ...headline.NewsItem) []headline.Response {
+		return []headline.Response{{Source: headline.SourceInfo{ID: "test"}, Headlines: items}}
+	}
+
+	// The initial page and failed refreshes are not published
+	feed.Publish(page(first))
+	feed.Publish([]headline.Response{{Source: headline.SourceInfo{ID: "test"}, Error: &headline.SourceError{}}})
+	feed.Publish(page(second, first))
+
+	select {
+	case item := <-items:
+		if item.(newsItem).Title != "Second" {
+			t.Errorf("Expected the new headline, got %+v", item)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the new headline to be published")
+	}
+
+	cancel()
+	for range items {
+	}
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	if len(feed.subscribers) != 0 {
+		t.Error("Expected the subscriber to be removed once its context is done")
+	}
+}
+
+func TestHandlerLimits(t *testing.T) {
+	data := testData(t)
+	fetches := 0
+	headlines := data.Headlines
+	data.Headlines = func() []headline.Response {
+		fetches++
+		return headlines()
+	}
+	schema, err := NewSchema(data)
+	if err != nil {
+		t.Fatalf("Error creating schema: %v", err)
+	}
+	handler := Handler(schema)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ headlines { title } sources { stale headlines { title } } }`), nil))
+	if rec.Code != http.StatusOK || fetches != 1 {
+		t.Errorf("Expected the headlines to be fetched once per query, got %d fetches: %d %s", fetches, rec.Code, rec.Body.String())
+	}
+
+	deep := "{ headlines { " + strings.Repeat("source { stories { ", 5) + "title" + strings.Repeat(" } }", 5) + " } }"
+	wide := "{ " + strings.Repeat("sources { id name logo homepage enabled } ", 50) + "}"
+	bomb := `query { ...a } fragment a on Query { sources { ...b ...b ...b } } fragment b on Source { stories { id title url } stories { id title url } }`
+	for _, tt := range []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"deep", deep, http.StatusBadRequest},
+		{"wide", wide, http.StatusBadRequest},
+		{"fragments", strings.Replace(bomb, "...b ...b ...b", strings.Repeat("...b ", 40), 1), http.StatusBadRequest},
+		{"within limits", bomb, http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(tt.query), nil))
+		if rec.Code != tt.status {
+			t.Errorf("Expected status %d for the %s query, got %d %s", tt.status, tt.name, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	body := `{"query":"{ sources { id } }","variables":{"padding":"` + strings.Repeat("x", maxBodySize) + `"}}`
+	handler(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected a body over the limit to be rejected, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,567 @@
+// Package graph serves the sources, their headlines, the stories of the history and the
+// archived front pages over GraphQL
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/shaharia-lab/headlines/analytics"
+	"github.com/shaharia-lab/headlines/archive"
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// Source is a registered news source and whether it is enabled
+type Source struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Logo     string `json:"logo"`
+	Homepage string `json:"homepage"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// Data is what the schema is resolved from, the same data the REST handlers serve
+type Data struct {
+	// Sources returns the enabled sources in the configured order followed by the other registered sources
+	Sources func() []Source
+	// Headlines returns the current headlines of the enabled sources, as served by /api/headlines
+	Headlines func() []headline.Response
+	History   *history.Store
+	// Archive is nil when the archive is disabled
+	Archive *archive.Archive
+	// Feed publishes the headlines for subscriptions
+	Feed *Feed
+}
+
+// newsItem is a headline with the ID of its source
+type newsItem struct {
+	Title      string
+	URL        string
+	Category   string
+	Rank       int
+	Prominence string
+	SourceID   string
+}
+
+func newNewsItem(source string, item headline.NewsItem) newsItem {
+	return newsItem{
+		Title:      item.Title,
+		URL:        item.URL,
+		Category:   item.Category,
+		Rank:       item.Rank,
+		Prominence: item.Prominence,
+		SourceID:   source,
+	}
+}
+
+// archivedPage is a raw page of a snapshot with its link
+type archivedPage struct {
+	URL  string
+	Hash string
+	Href string
+}
+
+type resolver struct {
+	data Data
+}
+
+// NewSchema creates the GraphQL schema
+func NewSchema(data Data) (graphql.Schema, error) {
+	r := &resolver{data: data}
+
+	source := graphql.NewObject(graphql.ObjectConfig{Name: "Source", Fields: graphql.Fields{}})
+	newsItemType := graphql.NewObject(graphql.ObjectConfig{Name: "NewsItem", Fields: graphql.Fields{}})
+	story := graphql.NewObject(graphql.ObjectConfig{Name: "Story", Fields: graphql.Fields{}})
+
+	sourceError := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "SourceError",
+		Description: "Why fetching the headlines of a source failed",
+		Fields: graphql.Fields{
+			"type":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "fetch_failed, robots_disallowed or circuit_open"},
+			"message": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	revision := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Revision",
+		Description: "A distinct title of a story",
+		Fields: graphql.Fields{
+			"title":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"firstSeen": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"lastSeen":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+		},
+	})
+	topStory := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "TopStory",
+		Description: "A story ranked across sources, with the stories of other sources covering it",
+		Fields: graphql.Fields{
+			"story": &graphql.Field{
+				Type: graphql.NewNonNull(story),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source.(analytics.TopStory).Article, nil
+				},
+			},
+			"score": &graphql.Field{Type: graphql.NewNonNull(graphql.Float)},
+			"coverage": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(story))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					coverage := p.Source.(analytics.TopStory).Coverage
+					if coverage == nil {
+						coverage = []history.Article{}
+					}
+					return coverage, nil
+				},
+			},
+		},
+	})
+	pageType := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "ArchivedPage",
+		Description: "A raw page a snapshot was parsed from",
+		Fields: graphql.Fields{
+			"url":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"hash": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"href": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		},
+	})
+	snapshot := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Snapshot",
+		Description: "A source's front page as it was archived",
+		Fields: graphql.Fields{
+			"at": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"source": &graphql.Field{
+				Type: graphql.NewNonNull(source),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return r.source(p.Source.(archive.Snapshot).Source), nil
+				},
+			},
+			"items": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(newsItemType))),
+				Args: withPagination(graphql.FieldConfigArgument{"category": categoryArg}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					s := p.Source.(archive.Snapshot)
+					return r.items([]headline.Response{{Source: headline.SourceInfo{ID: s.Source}, Headlines: s.Items}}, p.Args)
+				},
+			},
+			"pages": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(pageType))),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					pages := []archivedPage{}
+					for _, page := range p.Source.(archive.Snapshot).Pages {
+						pages = append(pages, archivedPage{URL: page.URL, Hash: page.Hash, Href: "/api/snapshots/pages/" + page.Hash})
+					}
+					return pages, nil
+				},
+			},
+		},
+	})
+
+	source.AddFieldConfig("id", &graphql.Field{Type: graphql.NewNonNull(graphql.ID)})
+	source.AddFieldConfig("name", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	source.AddFieldConfig("logo", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	source.AddFieldConfig("homepage", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	source.AddFieldConfig("enabled", &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)})
+	source.AddFieldConfig("headlines", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(newsItemType))),
+		Description: "The current headlines of the source, empty if it is not enabled",
+		Args:        withPagination(graphql.FieldConfigArgument{"category": categoryArg}),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			resp, _ := r.response(p.Context, p.Source.(Source).ID)
+			return r.items([]headline.Response{resp}, p.Args)
+		},
+	})
+	source.AddFieldConfig("stale", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.Boolean),
+		Description: "Whether the headlines are the last successful ones because the source is failing",
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			resp, _ := r.response(p.Context, p.Source.(Source).ID)
+			return resp.Stale, nil
+		},
+	})
+	source.AddFieldConfig("error", &graphql.Field{
+		Type: sourceError,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			if resp, ok := r.response(p.Context, p.Source.(Source).ID); ok && resp.Error != nil {
+				return resp.Error, nil
+			}
+			return nil, nil
+		},
+	})
+	source.AddFieldConfig("lastSuccessAt", &graphql.Field{
+		Type: graphql.DateTime,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			if resp, ok := r.response(p.Context, p.Source.(Source).ID); ok && resp.LastSuccessAt != nil {
+				return *resp.LastSuccessAt, nil
+			}
+			return nil, nil
+		},
+	})
+	source.AddFieldConfig("stories", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(story))),
+		Description: "The stories of the source seen within the window, most recently seen first",
+		Args:        withPagination(graphql.FieldConfigArgument{"window": windowArg, "category": categoryArg}),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			p.Args["source"] = p.Source.(Source).ID
+			return r.stories(p.Args)
+		},
+	})
+
+	newsItemType.AddFieldConfig("title", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	newsItemType.AddFieldConfig("url", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	newsItemType.AddFieldConfig("category", &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return optional(p.Source.(newsItem).Category), nil
+	}})
+	newsItemType.AddFieldConfig("rank", &graphql.Field{Type: graphql.NewNonNull(graphql.Int)})
+	newsItemType.AddFieldConfig("prominence", &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return optional(p.Source.(newsItem).Prominence), nil
+	}})
+	newsItemType.AddFieldConfig("source", &graphql.Field{
+		Type: graphql.NewNonNull(source),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return r.source(p.Source.(newsItem).SourceID), nil
+		},
+	})
+	newsItemType.AddFieldConfig("story", &graphql.Field{
+		Type:        story,
+		Description: "The story of the headline in the history",
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			if a, ok := r.data.History.Article(history.ArticleID(p.Source.(newsItem).URL)); ok {
+				return a, nil
+			}
+			return nil, nil
+		},
+	})
+
+	story.AddFieldConfig("id", &graphql.Field{Type: graphql.NewNonNull(graphql.ID)})
+	story.AddFieldConfig("title", &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "The title as last seen"})
+	story.AddFieldConfig("url", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	story.AddFieldConfig("category", &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (any, error) {
+		return optional(p.Source.(history.Article).Category), nil
+	}})
+	story.AddFieldConfig("rank", &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Description: "The rank as last seen"})
+	story.AddFieldConfig("prominence", &graphql.Field{Type: graphql.NewNonNull(graphql.String), Description: "The prominence as last seen"})
+	story.AddFieldConfig("bestRank", &graphql.Field{Type: graphql.NewNonNull(graphql.Int)})
+	story.AddFieldConfig("bestProminence", &graphql.Field{Type: graphql.NewNonNull(graphql.String)})
+	story.AddFieldConfig("firstSeen", &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)})
+	story.AddFieldConfig("lastSeen", &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)})
+	story.AddFieldConfig("source", &graphql.Field{
+		Type: graphql.NewNonNull(source),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			return r.source(p.Source.(history.Article).Source), nil
+		},
+	})
+	story.AddFieldConfig("revisions", &graphql.Field{
+		Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(revision))),
+		Description: "The distinct titles of the story, oldest first",
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			revisions, _ := r.data.History.Revisions(p.Source.(history.Article).ID)
+			if revisions == nil {
+				revisions = []history.Revision{}
+			}
+			return revisions, nil
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"sources": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(source))),
+				Description: "The registered sources, the enabled ones first in the configured order",
+				Args: graphql.FieldConfigArgument{
+					"enabled": &graphql.ArgumentConfig{Type: graphql.Boolean, Description: "Only list the enabled or the disabled sources"},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					sources := []Source{}
+					for _, s := range r.data.Sources() {
+						if enabled, ok := p.Args["enabled"].(bool); ok && s.Enabled != enabled {
+							continue
+						}
+						sources = append(sources, s)
+					}
+					return sources, nil
+				},
+			},
+			"source": &graphql.Field{
+				Type: source,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					for _, s := range r.data.Sources() {
+						if s.ID == p.Args["id"] {
+							return s, nil
+						}
+					}
+					return nil, nil
+				},
+			},
+			"headlines": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(newsItemType))),
+				Description: "The current headlines of the enabled sources, in the configured order of the sources",
+				Args:        withPagination(graphql.FieldConfigArgument{"source": sourceArg, "category": categoryArg}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					responses := r.headlines(p.Context)
+					if id, ok := p.Args["source"].(string); ok {
+						resp, _ := r.response(p.Context, id)
+						responses = []headline.Response{resp}
+					}
+					return r.items(responses, p.Args)
+				},
+			},
+			"stories": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(story))),
+				Description: "The stories seen within the window, most recently seen first",
+				Args:        withPagination(graphql.FieldConfigArgument{"window": windowArg, "source": sourceArg, "category": categoryArg}),
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return r.stories(p.Args)
+				},
+			},
+			"story": &graphql.Field{
+				Type: story,
+				Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if a, ok := r.data.History.Article(p.Args["id"].(string)); ok {
+						return a, nil
+					}
+					return nil, nil
+				},
+			},
+			"topStories": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(topStory))),
+				Description: "The stories of all sources seen within the window as a single ranked list, as /api/top",
+				Args: graphql.FieldConfigArgument{
+					"window": windowArg,
+					"limit":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: analytics.DefaultTopOptions.Limit},
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					window, err := windowParam(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					limit := p.Args["limit"].(int)
+					if limit <= 0 {
+						return nil, fmt.Errorf("invalid limit %d, expected a positive integer", limit)
+					}
+					now := time.Now()
+					opts := analytics.DefaultTopOptions
+					opts.Limit = limit
+					stories := analytics.Top(r.data.History.Articles(now.Add(-window)), now, opts)
+					if stories == nil {
+						stories = []analytics.TopStory{}
+					}
+					return stories, nil
+				},
+			},
+			"snapshots": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(snapshot))),
+				Description: "The front pages as they were at the given time, the current ones without it. Requires the archive.",
+				Args: graphql.FieldConfigArgument{
+					"at":     &graphql.ArgumentConfig{Type: graphql.DateTime},
+					"source": sourceArg,
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					if r.data.Archive == nil {
+						return nil, errors.New("the archive is disabled")
+					}
+					at := time.Now()
+					if t, ok := p.Args["at"].(time.Time); ok {
+						at = t
+					}
+					sourceID, _ := p.Args["source"].(string)
+					snapshots := r.data.Archive.At(sourceID, at)
+					if snapshots == nil {
+						snapshots = []archive.Snapshot{}
+					}
+					return snapshots, nil
+				},
+			},
+		},
+	})
+
+	subscription := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Subscription",
+		Fields: graphql.Fields{
+			"headlineAdded": &graphql.Field{
+				Type:        graphql.NewNonNull(newsItemType),
+				Description: "Every headline that appears on a front page",
+				Args:        graphql.FieldConfigArgument{"source": sourceArg, "category": categoryArg},
+				Subscribe: func(p graphql.ResolveParams) (any, error) {
+					sourceID, _ := p.Args["source"].(string)
+					category, err := categoryParam(p.Args)
+					if err != nil {
+						return nil, err
+					}
+					return r.data.Feed.Subscribe(p.Context, func(item newsItem) bool {
+						return (sourceID == "" || item.SourceID == sourceID) && (category == "" || item.Category == category)
+					}), nil
+				},
+				Resolve: func(p graphql.ResolveParams) (any, error) {
+					return p.Source, nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query, Subscription: subscription})
+}
+
+var (
+	sourceArg   = &graphql.ArgumentConfig{Type: graphql.ID, Description: "Only include this source ID"}
+	categoryArg = &graphql.ArgumentConfig{Type: graphql.String, Description: "Only include this category, in English or Bengali"}
+	windowArg   = &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "24h", Description: "A duration such as 24h"}
+)
+
+// withPagination adds the limit and offset arguments to args
+func withPagination(args graphql.FieldConfigArgument) graphql.FieldConfigArgument {
+	args["limit"] = &graphql.ArgumentConfig{Type: graphql.Int, Description: "The maximum number of results, all when omitted"}
+	args["offset"] = &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 0, Description: "The number of results skipped"}
+	return args
+}
+
+// paginate returns the bounds of the page of n results selected by the limit and offset arguments
+func paginate(n int, args map[string]any) (int, int, error) {
+	offset, _ := args["offset"].(int)
+	if offset < 0 {
+		return 0, 0, fmt.Errorf("invalid offset %d, expected a non-negative integer", offset)
+	}
+	start := min(offset, n)
+	limit, ok := args["limit"].(int)
+	if !ok {
+		return start, n, nil
+	}
+	if limit <= 0 {
+		return 0, 0, fmt.Errorf("invalid limit %d, expected a positive integer", limit)
+	}
+	return start, min(start+limit, n), nil
+}
+
+func windowParam(args map[string]any) (time.Duration, error) {
+	value, _ := args["window"].(string)
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q, expected a positive duration such as 24h", value)
+	}
+	return d, nil
+}
+
+// categoryParam returns the normalized category argument, empty when it is not set
+func categoryParam(args map[string]any) (string, error) {
+	value, ok := args["category"].(string)
+	if !ok || value == "" {
+		return "", nil
+	}
+	category := headline.NormalizeCategory(value)
+	if category == "" {
+		return "", fmt.Errorf("unknown category %q, expected one of %s", value, strings.Join(headline.Categories(), ", "))
+	}
+	return category, nil
+}
+
+// optional returns nil for an empty string so that it is null in the response
+func optional(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// source returns the source with the given ID. Sources that are no longer registered, e.g. in
+// the history, are described by their ID.
+func (r *resolver) source(id string) Source {
+	for _, s := range r.data.Sources() {
+		if s.ID == id {
+			return s
+		}
+	}
+	return Source{ID: id, Name: id}
+}
+
+// requestHeadlines are the headlines of a request, fetched once so that its fields are resolved
+// from the same snapshot without fetching the headlines again for every source
+type requestHeadlines struct {
+	once      sync.Once
+	responses []headline.Response
+}
+
+type requestHeadlinesKey struct{}
+
+// withRequestHeadlines returns a context in which the headlines are fetched at most once
+func withRequestHeadlines(ctx context.Context) context.Context {
+	return context.WithValue(ctx, requestHeadlinesKey{}, &requestHeadlines{})
+}
+
+// headlines returns the current headlines, those of the request when ctx has them. The context
+// is nil when the schema is executed without one.
+func (r *resolver) headlines(ctx context.Context) []headline.Response {
+	var h *requestHeadlines
+	if ctx != nil {
+		h, _ = ctx.Value(requestHeadlinesKey{}).(*requestHeadlines)
+	}
+	if h == nil {
+		return r.data.Headlines()
+	}
+	h.once.Do(func() {
+		h.responses = r.data.Headlines()
+	})
+	return h.responses
+}
+
+// response returns the current response of an enabled source
+func (r *resolver) response(ctx context.Context, id string) (headline.Response, bool) {
+	for _, resp := range r.headlines(ctx) {
+		if resp.Source.ID == id {
+			return resp, true
+		}
+	}
+	return headline.Response{Source: headline.SourceInfo{ID: id}}, false
+}
+
+// items returns the page of the headlines of the responses selected by the category, limit and offset arguments
+func (r *resolver) items(responses []headline.Response, args map[string]any) ([]newsItem, error) {
+	category, err := categoryParam(args)
+	if err != nil {
+		return nil, err
+	}
+	if category != "" {
+		responses = headline.FilterCategory(responses, category)
+	}
+	items := []newsItem{}
+	for _, resp := range responses {
+		for _, item := range resp.Headlines {
+			items = append(items, newNewsItem(resp.Source.ID, item))
+		}
+	}
+	start, end, err := paginate(len(items), args)
+	if err != nil {
+		return nil, err
+	}
+	return items[start:end], nil
+}
+
+// stories returns the page of the stories selected by the window, source, category, limit and offset arguments
+func (r *resolver) stories(args map[string]any) ([]history.Article, error) {
+	window, err := windowParam(args)
+	if err != nil {
+		return nil, err
+	}
+	category, err := categoryParam(args)
+	if err != nil {
+		return nil, err
+	}
+	source, _ := args["source"].(string)
+
+	stories := []history.Article{}
+	for _, a := range r.data.History.Articles(time.Now().Add(-window)) {
+		if (source == "" || a.Source == source) && (category == "" || a.Category == category) {
+			stories = append(stories, a)
+		}
+	}
+	start, end, err := paginate(len(stories), args)
+	if err != nil {
+		return nil, err
+	}
+	return stories[start:end], nil
+}
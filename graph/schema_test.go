@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+func testData(t *testing.T) Data {
+	store, err := history.Open("", 0)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	responses := []headline.Response{
+		{Source: headline.SourceInfo{ID: "mzamin", Name: "Manab Zamin"}, Headlines: []headline.NewsItem{
+			{Title: "Budget passed", URL: "https://mzamin.com/1", Category: "politics", Rank: 1, Prominence: headline.ProminenceLead},
+			{Title: "Cricket win", URL: "https://mzamin.com/2", Category: "sports", Rank: 2, Prominence: headline.ProminenceTop},
+			{Title: "Rain today", URL: "https://mzamin.com/3", Rank: 3, Prominence: headline.ProminenceRegular},
+		}},
+		{Source: headline.SourceInfo{ID: "prothomalo", Name: "Prothom Alo"}, Error: &headline.SourceError{Type: headline.ErrorTypeFetchFailed, Message: "timeout"}},
+	}
+	store.Record(time.Now().Add(-time.Hour), responses)
+	store.Record(time.Now(), []headline.Response{{Source: responses[0].Source, Headlines: []headline.NewsItem{
+		{Title: "Budget passed after debate", URL: "https://mzamin.com/1", Category: "politics", Rank: 1, Prominence: headline.ProminenceLead},
+	}}})
+
+	return Data{
+		Sources: func() []Source {
+			return []Source{
+				{ID: "mzamin", Name: "Manab Zamin", Enabled: true},
+				{ID: "prothomalo", Name: "Prothom Alo", Enabled: true},
+				{ID: "dailystarbangla", Name: "The Daily Star Bangla"},
+			}
+		},
+		Headlines: func() []headline.Response { return responses },
+		History:   store,
+		Feed:      NewFeed(),
+	}
+}
+
+// execute runs the query and returns its data as JSON, failing on errors
+func execute(t *testing.T, schema graphql.Schema, query string) string {
+	t.Helper()
+	result := graphql.Do(graphql.Params{Schema: schema, RequestString: query})
+	if result.HasErrors() {
+		t.Fatalf("Unexpected errors for %s: %v", query, result.Errors)
+	}
+	data, _ := json.Marshal(result.Data)
+	return string(data)
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := NewSchema(testData(t))
+	if err != nil {
+		t.Fatalf("Error creating schema: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "sources",
+			query: `{ sources(enabled: true) { id stale error { type } } }`,
+			want:  `{"sources":[{"error":null,"id":"mzamin","stale":false},{"error":{"type":"fetch_failed"},"id":"prothomalo","stale":false}]}`,
+		},
+		{
+			name:  "paginated headlines",
+			query: `{ headlines(limit: 1, offset: 1) { title rank source { name } } }`,
+			want:  `{"headlines":[{"rank":2,"source":{"name":"Manab Zamin"},"title":"Cricket win"}]}`,
+		},
+		{
+			name:  "headlines by category in Bengali",
+			query: `{ source(id: "mzamin") { headlines(category: "খেলা") { title category } } }`,
+			want:  `{"source":{"headlines":[{"category":"sports","title":"Cricket win"}]}}`,
+		},
+		{
+			name:  "story of a headline",
+			query: `{ headlines(limit: 1) { story { title bestRank revisions { title } } } }`,
+			want:  `{"headlines":[{"story":{"bestRank":1,"revisions":[{"title":"Budget passed"},{"title":"Budget passed after debate"}],"title":"Budget passed after debate"}}]}`,
+		},
+		{
+			name:  "stories of a source",
+			query: `{ stories(source: "mzamin", category: "sports") { title source { id } } }`,
+			want:  `{"stories":[{"source":{"id":"mzamin"},"title":"Cricket win"}]}`,
+		},
+		{
+			name:  "top stories",
+			query: `{ topStories(limit: 1) { story { title } coverage { id } } }`,
+			want:  `{"topStories":[{"coverage":[],"story":{"title":"Budget passed after debate"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, schema, tt.query); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSchemaErrors(t *testing.T) {
+	schema, err := NewSchema(testData(t))
+	if err != nil {
+		t.Fatalf("Error creating schema: %v", err)
+	}
+
+	for _, query := range []string{
+		`{ headlines(category: "weather") { title } }`,
+		`{ headlines(limit: 0) { title } }`,
+		`{ stories(window: "yesterday") { title } }`,
+		`{ snapshots { at } }`,
+	} {
+		if result := graphql.Do(graphql.Params{Schema: schema, RequestString: query}); !result.HasErrors() {
+			t.Errorf("Expected an error for %s", query)
+		}
+	}
+}
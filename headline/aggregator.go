@@ -0,0 +1,197 @@
+package headline
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CachePolicy is how an Aggregator caches the headlines of its sources
+type CachePolicy struct {
+	// Duration is how long the aggregated headlines are served from the cache. Zero disables the cache.
+	Duration time.Duration
+	// StaleMaxAge is how long the last good headlines of a failing source are served. Zero disables it.
+	StaleMaxAge time.Duration
+}
+
+// DefaultCachePolicy caches the headlines for a minute and serves the last good headlines of a
+// failing source for an hour
+var DefaultCachePolicy = CachePolicy{Duration: time.Minute, StaleMaxAge: time.Hour}
+
+// Aggregator fetches the headlines of its sources concurrently and caches them
+type Aggregator struct {
+	sources func() []NewsClient
+	policy  CachePolicy
+	now     func() time.Time
+	logger  *log.Logger
+
+	// refreshes lets concurrent requests finding the cache expired share a single fetch
+	refreshes singleflight.Group
+
+	mu     sync.Mutex
+	cached *CachedResponse
+	// lastGood holds the last successful response of each source, by sourceKey
+	lastGood map[string]Response
+}
+
+// AggregatorOption configures an Aggregator
+type AggregatorOption func(*Aggregator)
+
+// WithClock sets the clock of the aggregator, which decides when cached and stale headlines expire
+func WithClock(now func() time.Time) AggregatorOption {
+	return func(a *Aggregator) {
+		a.now = now
+	}
+}
+
+// WithLogger sets the logger the failing sources are reported to
+func WithLogger(logger *log.Logger) AggregatorOption {
+	return func(a *Aggregator) {
+		a.logger = logger
+	}
+}
+
+// NewAggregator creates an Aggregator of the sources. sources is called on every fetch, so
+// that sources reloaded while the aggregator runs are picked up.
+func NewAggregator(sources func() []NewsClient, policy CachePolicy, opts ...AggregatorOption) *Aggregator {
+	a := &Aggregator{
+		sources:  sources,
+		policy:   policy,
+		now:      time.Now,
+		logger:   log.Default(),
+		lastGood: make(map[string]Response),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Sources returns the sources of the aggregator
+func (a *Aggregator) Sources() []NewsClient {
+	return a.sources()
+}
+
+// CacheDuration returns how long cached headlines are served
+func (a *Aggregator) CacheDuration() time.Duration {
+	return a.policy.Duration
+}
+
+// GetHeadlines fetches the headlines of the sources, bypassing the cache, until ctx is done.
+// A failing source is served its last successful headlines, marked as stale, as long as they
+// are not older than the stale max age.
+func (a *Aggregator) GetHeadlines(ctx context.Context) []Response {
+	sources := a.sources()
+	var wg sync.WaitGroup
+	results := make([]Response, len(sources))
+
+	for i, source := range sources {
+		wg.Add(1)
+		go func(index int, s NewsClient) {
+			defer wg.Done()
+			items, err := s.GetHeadlines(ctx)
+			if err != nil {
+				a.logger.Printf("Error fetching headlines from %s: %v", s.SourceInfo().Name, err)
+				results[index] = a.lastGoodOrEmpty(s.SourceInfo(), err)
+			} else {
+				results[index] = a.rememberLastGood(items)
+			}
+		}(i, source)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// rememberLastGood stores a successful response as the source's last good headlines
+func (a *Aggregator) rememberLastGood(resp Response) Response {
+	now := a.now()
+	resp.LastSuccessAt = &now
+	a.mu.Lock()
+	a.lastGood[sourceKey(resp.Source)] = resp
+	a.mu.Unlock()
+	return resp
+}
+
+// lastGoodOrEmpty returns the last good headlines of a failing source marked as stale,
+// or a response without headlines if there are none recent enough
+func (a *Aggregator) lastGoodOrEmpty(info SourceInfo, err error) Response {
+	a.mu.Lock()
+	resp, ok := a.lastGood[sourceKey(info)]
+	a.mu.Unlock()
+	if ok && a.policy.StaleMaxAge > 0 && a.now().Sub(*resp.LastSuccessAt) <= a.policy.StaleMaxAge {
+		resp.Stale = true
+		resp.Error = newSourceError(err)
+		return resp
+	}
+	return Response{Source: info, Headlines: nil, Error: newSourceError(err)}
+}
+
+func sourceKey(info SourceInfo) string {
+	if info.ID != "" {
+		return info.ID
+	}
+	return info.Name
+}
+
+// GetCachedHeadlines returns cached headlines if they are not expired
+func (a *Aggregator) GetCachedHeadlines() ([]Response, bool) {
+	cached, ok := a.CachedHeadlines()
+	return cached.Body, ok
+}
+
+// CachedHeadlines returns the cached headlines with the time they were cached, if they are not expired
+func (a *Aggregator) CachedHeadlines() (CachedResponse, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.cached != nil && a.now().Sub(a.cached.Timestamp) < a.policy.Duration {
+		return *a.cached, true
+	}
+	return CachedResponse{}, false
+}
+
+// Headlines returns the cached headlines, fetching and caching them if the cache expired.
+// The boolean reports whether they were served from the cache. The fetch is not cancelled with
+// the request that started it, as the headlines are cached for every other request. Concurrent
+// calls finding the cache expired wait for the same fetch.
+func (a *Aggregator) Headlines() (CachedResponse, bool) {
+	if cached, ok := a.CachedHeadlines(); ok {
+		return cached, true
+	}
+	refreshed, _, _ := a.refreshes.Do("headlines", func() (any, error) {
+		return a.Refresh(context.Background()), nil
+	})
+	return refreshed.(CachedResponse), false
+}
+
+// Refresh fetches the headlines of the sources until ctx is done and caches them
+func (a *Aggregator) Refresh(ctx context.Context) CachedResponse {
+	return a.CacheHeadlines(a.GetHeadlines(ctx))
+}
+
+// CacheHeadlines caches the provided headlines and returns the cache entry
+func (a *Aggregator) CacheHeadlines(headlines []Response) CachedResponse {
+	cached := CachedResponse{
+		Body:      headlines,
+		Timestamp: a.now(),
+	}
+	a.mu.Lock()
+	a.cached = &cached
+	a.mu.Unlock()
+	return cached
+}
+
+// Age returns how long ago the cache entry was created, by the clock of the aggregator
+func (a *Aggregator) Age(cached CachedResponse) time.Duration {
+	return max(a.now().Sub(cached.Timestamp), 0)
+}
+
+// ClearCachedHeadlines removes the cached headlines
+func (a *Aggregator) ClearCachedHeadlines() {
+	a.mu.Lock()
+	a.cached = nil
+	a.mu.Unlock()
+}
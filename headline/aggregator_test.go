@@ -0,0 +1,193 @@
+package headline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when advanced
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func staticSources(sources ...NewsClient) func() []NewsClient {
+	return func() []NewsClient { return sources }
+}
+
+func TestAggregatorCache(t *testing.T) {
+	clock := newFakeClock()
+	source := &FlakyNewsClient{MockNewsClient: MockNewsClient{headlines: []NewsItem{{Title: "Test 1", URL: "http://test1.com"}}}}
+	aggregator := NewAggregator(staticSources(source), CachePolicy{Duration: time.Minute}, WithClock(clock.Now))
+
+	if _, isCached := aggregator.GetCachedHeadlines(); isCached {
+		t.Error("Expected an empty cache")
+	}
+
+	first, isCached := aggregator.Headlines()
+	if isCached || !first.Timestamp.Equal(clock.Now()) || source.calls != 1 {
+		t.Fatalf("Expected the headlines to be fetched, got %+v after %d calls", first, source.calls)
+	}
+
+	clock.Advance(59 * time.Second)
+	cached, isCached := aggregator.Headlines()
+	if !isCached || !reflect.DeepEqual(cached, first) || source.calls != 1 {
+		t.Errorf("Expected the cached headlines, got %+v after %d calls", cached, source.calls)
+	}
+	if age := aggregator.Age(cached); age != 59*time.Second {
+		t.Errorf("Expected the headlines to be 59s old, got %s", age)
+	}
+
+	clock.Advance(time.Second)
+	if _, isCached := aggregator.GetCachedHeadlines(); isCached {
+		t.Error("Expected the cache to be expired")
+	}
+	if _, isCached := aggregator.Headlines(); isCached || source.calls != 2 {
+		t.Errorf("Expected the headlines to be fetched again, got %d calls", source.calls)
+	}
+
+	aggregator.ClearCachedHeadlines()
+	if _, isCached := aggregator.GetCachedHeadlines(); isCached {
+		t.Error("Expected the cache to be cleared")
+	}
+}
+
+func TestAggregatorsAreIndependent(t *testing.T) {
+	first := NewAggregator(staticSources(&MockNewsClient{headlines: []NewsItem{{Title: "Test 1", URL: "http://test1.com"}}}), DefaultCachePolicy)
+	second := NewAggregator(staticSources(&MockNewsClient{headlines: []NewsItem{{Title: "Test 2", URL: "http://test2.com"}}}), DefaultCachePolicy)
+
+	first.Refresh(context.Background())
+	if _, isCached := second.GetCachedHeadlines(); isCached {
+		t.Error("Expected the cache of an aggregator not to be shared")
+	}
+	second.Refresh(context.Background())
+	if headlines, _ := first.GetCachedHeadlines(); headlines[0].Headlines[0].Title != "Test 1" {
+		t.Errorf("Expected the headlines of the first aggregator, got %+v", headlines)
+	}
+}
+
+func TestAggregatorGetHeadlines(t *testing.T) {
+	mockClient1 := &MockNewsClient{
+		headlines: []NewsItem{{Title: "Test 1", URL: "http://test1.com"}},
+	}
+	mockClient2 := &MockNewsClient{
+		headlines: []NewsItem{{Title: "Test 2", URL: "http://test2.com"}},
+	}
+
+	results := NewAggregator(staticSources(mockClient1, mockClient2), DefaultCachePolicy).GetHeadlines(context.Background())
+
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+
+	if results[0].Headlines[0].Title != "Test 1" || results[1].Headlines[0].Title != "Test 2" {
+		t.Error("Unexpected headlines in results")
+	}
+}
+
+func TestAggregatorServesLastGood(t *testing.T) {
+	clock := newFakeClock()
+	var logs bytes.Buffer
+	source := &FlakyNewsClient{MockNewsClient: MockNewsClient{headlines: []NewsItem{{Title: "Test 1", URL: "http://test1.com"}}}}
+	aggregator := NewAggregator(staticSources(source), CachePolicy{StaleMaxAge: time.Hour}, WithClock(clock.Now), WithLogger(log.New(&logs, "", 0)))
+
+	results := aggregator.GetHeadlines(context.Background())
+	if results[0].Stale || results[0].LastSuccessAt == nil || !results[0].LastSuccessAt.Equal(clock.Now()) {
+		t.Fatalf("Expected fresh headlines with a success timestamp, got %+v", results[0])
+	}
+	lastSuccessAt := *results[0].LastSuccessAt
+
+	// A failing source keeps its last good headlines
+	source.err = errors.New("timeout")
+	clock.Advance(time.Hour)
+	results = aggregator.GetHeadlines(context.Background())
+	if !results[0].Stale || len(results[0].Headlines) != 1 || results[0].Error == nil {
+		t.Errorf("Expected stale headlines with an error, got %+v", results[0])
+	}
+	if results[0].LastSuccessAt == nil || !results[0].LastSuccessAt.Equal(lastSuccessAt) {
+		t.Errorf("Expected the last success timestamp %s, got %v", lastSuccessAt, results[0].LastSuccessAt)
+	}
+	if !strings.Contains(logs.String(), "Error fetching headlines from Mock Source: timeout") {
+		t.Errorf("Expected the failure to be logged, got %q", logs.String())
+	}
+
+	// Headlines older than the max age are not served
+	clock.Advance(time.Second)
+	results = aggregator.GetHeadlines(context.Background())
+	if results[0].Stale || results[0].Headlines != nil || results[0].Error == nil {
+		t.Errorf("Expected no headlines once the last good ones are too old, got %+v", results[0])
+	}
+}
+
+func TestAggregatorStaleDisabled(t *testing.T) {
+	source := &FlakyNewsClient{MockNewsClient: MockNewsClient{headlines: []NewsItem{{Title: "Test 1", URL: "http://test1.com"}}}}
+	aggregator := NewAggregator(staticSources(source), CachePolicy{}, WithClock(newFakeClock().Now), WithLogger(log.New(io.Discard, "", 0)))
+	aggregator.GetHeadlines(context.Background())
+
+	// Without a stale max age, the last good headlines are not served even at the same instant
+	source.err = errors.New("timeout")
+	results := aggregator.GetHeadlines(context.Background())
+	if results[0].Stale || results[0].Headlines != nil {
+		t.Errorf("Expected no stale headlines when they are disabled, got %+v", results[0])
+	}
+}
+
+// blockingNewsClient is a NewsClient whose fetches wait until release is closed
+type blockingNewsClient struct {
+	MockNewsClient
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (b *blockingNewsClient) GetHeadlines(ctx context.Context) (Response, error) {
+	b.calls.Add(1)
+	<-b.release
+	return b.MockNewsClient.GetHeadlines(ctx)
+}
+
+func TestAggregatorHeadlinesSingleFetch(t *testing.T) {
+	source := &blockingNewsClient{release: make(chan struct{})}
+	aggregator := NewAggregator(staticSources(source), DefaultCachePolicy)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			aggregator.Headlines()
+		}()
+	}
+	// Let the requests find the cache expired before the fetch completes
+	time.Sleep(50 * time.Millisecond)
+	close(source.release)
+	wg.Wait()
+
+	if calls := source.calls.Load(); calls != 1 {
+		t.Errorf("Expected the concurrent requests to share a single fetch, got %d", calls)
+	}
+}
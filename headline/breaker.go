@@ -0,0 +1,157 @@
+package headline
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a source is not fetched because its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+// Circuit breaker states
+const (
+	// BreakerClosed lets every request through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects requests until the open timeout has passed
+	BreakerOpen
+	// BreakerHalfOpen lets a single trial request through to test whether the source recovered
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// BreakerSettings configures a circuit breaker
+type BreakerSettings struct {
+	// FailureThreshold is the number of consecutive failures that opens the circuit
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a trial request is let through
+	OpenTimeout time.Duration
+}
+
+// CircuitBreakerClient wraps a NewsClient with a circuit breaker. While the circuit is open
+// the source is not called and ErrCircuitOpen is returned.
+type CircuitBreakerClient struct {
+	client   NewsClient
+	settings BreakerSettings
+	now      func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+// NewCircuitBreakerClient creates a new CircuitBreakerClient
+func NewCircuitBreakerClient(client NewsClient, settings BreakerSettings) *CircuitBreakerClient {
+	return &CircuitBreakerClient{
+		client:   client,
+		settings: settings,
+		now:      time.Now,
+	}
+}
+
+// SourceInfo returns information about the news source
+func (c *CircuitBreakerClient) SourceInfo() SourceInfo {
+	return c.client.SourceInfo()
+}
+
+// State returns the current state of the circuit breaker
+func (c *CircuitBreakerClient) State() BreakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// GetHeadlines fetches the headlines from the wrapped client unless the circuit is open
+func (c *CircuitBreakerClient) GetHeadlines(ctx context.Context) (Response, error) {
+	if !c.allow() {
+		return Response{Source: c.client.SourceInfo()}, ErrCircuitOpen
+	}
+
+	resp, err := c.client.GetHeadlines(ctx)
+	if err != nil {
+		// A fetch cancelled by the caller says nothing about the health of the source
+		if ctx.Err() != nil {
+			c.onCancelled()
+		} else {
+			c.onFailure()
+		}
+		return resp, err
+	}
+
+	c.onSuccess()
+	return resp, nil
+}
+
+// allow reports whether a request may be made, moving an expired open circuit to half-open
+func (c *CircuitBreakerClient) allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch c.state {
+	case BreakerOpen:
+		if c.now().Sub(c.openedAt) < c.settings.OpenTimeout {
+			return false
+		}
+		c.state = BreakerHalfOpen
+		return true
+	case BreakerHalfOpen:
+		// A trial request is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *CircuitBreakerClient) onSuccess() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state != BreakerClosed {
+		log.Printf("Circuit breaker for %s closed", c.client.SourceInfo().Name)
+	}
+	c.state = BreakerClosed
+	c.failures = 0
+}
+
+func (c *CircuitBreakerClient) onFailure() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.failures++
+	if c.state == BreakerHalfOpen || c.failures >= c.settings.FailureThreshold {
+		if c.state != BreakerOpen {
+			log.Printf("Circuit breaker for %s opened after %d failures", c.client.SourceInfo().Name, c.failures)
+		}
+		c.state = BreakerOpen
+		c.openedAt = c.now()
+	}
+}
+
+// onCancelled releases the trial of a half-open circuit, so that the next request is the trial
+func (c *CircuitBreakerClient) onCancelled() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == BreakerHalfOpen {
+		// openedAt is kept, so the open timeout has already passed
+		c.state = BreakerOpen
+	}
+}
@@ -0,0 +1,107 @@
+package headline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// FlakyNewsClient is a NewsClient that fails while err is set
+type FlakyNewsClient struct {
+	MockNewsClient
+	err   error
+	calls int
+}
+
+func (f *FlakyNewsClient) GetHeadlines(ctx context.Context) (Response, error) {
+	f.calls++
+	if f.err != nil {
+		return Response{Source: f.SourceInfo()}, f.err
+	}
+	return f.MockNewsClient.GetHeadlines(ctx)
+}
+
+func TestCircuitBreakerClient(t *testing.T) {
+	source := &FlakyNewsClient{MockNewsClient: MockNewsClient{headlines: []NewsItem{{Title: "Test 1", URL: "http://test1.com"}}}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := NewCircuitBreakerClient(source, BreakerSettings{FailureThreshold: 2, OpenTimeout: time.Minute})
+	client.now = func() time.Time { return now }
+
+	if _, err := client.GetHeadlines(context.Background()); err != nil {
+		t.Fatalf("Expected headlines, got %v", err)
+	}
+
+	// Consecutive failures open the circuit
+	source.err = errors.New("timeout")
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetHeadlines(context.Background()); !errors.Is(err, source.err) {
+			t.Errorf("Expected the source error, got %v", err)
+		}
+	}
+	if client.State() != BreakerOpen {
+		t.Fatalf("Expected the circuit to be open, got %s", client.State())
+	}
+
+	// While open the source is not called
+	calls := source.calls
+	if _, err := client.GetHeadlines(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if source.calls != calls {
+		t.Error("Expected the source not to be called while the circuit is open")
+	}
+
+	// A failed trial after the timeout opens the circuit again
+	now = now.Add(time.Minute)
+	client.GetHeadlines(context.Background())
+	if source.calls != calls+1 || client.State() != BreakerOpen {
+		t.Errorf("Expected a failed trial to reopen the circuit, got %s", client.State())
+	}
+
+	// A successful trial closes it
+	now = now.Add(time.Minute)
+	source.err = nil
+	if _, err := client.GetHeadlines(context.Background()); err != nil {
+		t.Errorf("Expected headlines after recovery, got %v", err)
+	}
+	if client.State() != BreakerClosed {
+		t.Errorf("Expected the circuit to be closed, got %s", client.State())
+	}
+}
+
+func TestCircuitBreakerClientCancelled(t *testing.T) {
+	source := &FlakyNewsClient{err: context.Canceled}
+	client := NewCircuitBreakerClient(source, BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.GetHeadlines(ctx)
+	if client.State() != BreakerClosed {
+		t.Errorf("Expected a cancelled fetch not to open the circuit, got %s", client.State())
+	}
+}
+
+func TestCircuitBreakerClientCancelledTrial(t *testing.T) {
+	source := &FlakyNewsClient{err: errors.New("timeout")}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := NewCircuitBreakerClient(source, BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute})
+	client.now = func() time.Time { return now }
+	client.GetHeadlines(context.Background())
+
+	// The trial after the timeout is cancelled
+	now = now.Add(time.Minute)
+	source.err = context.Canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.GetHeadlines(ctx)
+
+	source.err = nil
+	calls := source.calls
+	if _, err := client.GetHeadlines(context.Background()); err != nil {
+		t.Errorf("Expected the next call to be the trial, got %v", err)
+	}
+	if source.calls != calls+1 || client.State() != BreakerClosed {
+		t.Errorf("Expected the trial to close the circuit, got %s", client.State())
+	}
+}
@@ -0,0 +1,183 @@
+package headline
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+)
+
+// Categories of the cross-source taxonomy
+const (
+	CategoryNational      = "national"
+	CategoryPolitics      = "politics"
+	CategoryInternational = "international"
+	CategoryBusiness      = "business"
+	CategorySports        = "sports"
+	CategoryEntertainment = "entertainment"
+	CategoryTechnology    = "technology"
+	CategoryOpinion       = "opinion"
+	CategoryLifestyle     = "lifestyle"
+)
+
+// categoryAliases maps the section names and URL slugs used by the outlets to a category
+var categoryAliases = map[string][]string{
+	CategoryNational:      {"bangladesh", "country", "national", "city", "dhaka", "district", "দেশ", "বাংলাদেশ", "জাতীয়", "সারাদেশ", "সারা দেশ", "রাজধানী"},
+	CategoryPolitics:      {"politics", "political", "রাজনীতি"},
+	CategoryInternational: {"international", "world", "abroad", "আন্তর্জাতিক", "বিশ্ব", "বিদেশ"},
+	CategoryBusiness:      {"business", "economy", "economics", "বাণিজ্য", "অর্থনীতি", "অর্থনীতি-বাণিজ্য", "অর্থ-বাণিজ্য"},
+	CategorySports:        {"sports", "sport", "খেলা", "খেলাধুলা"},
+	CategoryEntertainment: {"entertainment", "showbiz", "বিনোদন"},
+	CategoryTechnology:    {"technology", "tech", "tech-startup", "science-technology", "প্রযুক্তি", "বিজ্ঞান-প্রযুক্তি", "বিজ্ঞান ও প্রযুক্তি"},
+	CategoryOpinion:       {"opinion", "editorial", "মতামত", "সম্পাদকীয়"},
+	CategoryLifestyle:     {"lifestyle", "life-living", "জীবনযাপন", "জীবনধারা"},
+}
+
+// categoryIndex is categoryAliases inverted, keyed by normalized alias
+var categoryIndex = make(map[string]string)
+
+func init() {
+	for category, aliases := range categoryAliases {
+		categoryIndex[category] = category
+		for _, alias := range aliases {
+			categoryIndex[categoryKey(alias)] = category
+		}
+	}
+}
+
+func categoryKey(name string) string {
+	return strings.ToLower(normalizeText(name))
+}
+
+// Categories returns all categories of the taxonomy
+func Categories() []string {
+	return []string{
+		CategoryNational, CategoryPolitics, CategoryInternational, CategoryBusiness, CategorySports,
+		CategoryEntertainment, CategoryTechnology, CategoryOpinion, CategoryLifestyle,
+	}
+}
+
+// NormalizeCategory maps a section name, in English or Bengali, to its category.
+// It returns an empty string for names that are not part of the taxonomy.
+func NormalizeCategory(name string) string {
+	return categoryIndex[categoryKey(name)]
+}
+
+// CategoryFromURL infers the category of an article from the sections in its URL path,
+// e.g. https://www.prothomalo.com/sports/cricket/some-story is in sports. The last path
+// segment is the article itself and is not considered.
+func CategoryFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for _, segment := range segments[:len(segments)-1] {
+		if category := NormalizeCategory(segment); category != "" {
+			return category
+		}
+	}
+	return ""
+}
+
+// Section is a section page of a news source
+type Section struct {
+	// Category is the category of the headlines on the section page
+	Category string
+	// Client fetches the headlines of the section page
+	Client NewsClient
+}
+
+// CategorizedClient wraps a NewsClient to tag its headlines with categories. Headlines of the
+// wrapped client are categorized by their URL; section pages are fetched as well and their
+// headlines are tagged with the category of the section.
+type CategorizedClient struct {
+	client   NewsClient
+	sections []Section
+}
+
+// NewCategorizedClient creates a new CategorizedClient. It returns an error if a section's category
+// is not part of the taxonomy.
+func NewCategorizedClient(client NewsClient, sections []Section) (*CategorizedClient, error) {
+	normalized := make([]Section, len(sections))
+	for i, section := range sections {
+		category := NormalizeCategory(section.Category)
+		if category == "" {
+			return nil, fmt.Errorf("unknown category %q, expected one of %s", section.Category, strings.Join(Categories(), ", "))
+		}
+		normalized[i] = Section{Category: category, Client: section.Client}
+	}
+	return &CategorizedClient{client: client, sections: normalized}, nil
+}
+
+// SourceInfo returns information about the news source
+func (c *CategorizedClient) SourceInfo() SourceInfo {
+	return c.client.SourceInfo()
+}
+
+// GetHeadlines fetches the headlines of the source and its sections. A failing section is
+// logged and skipped, while a failing front page fails the source, as the sections alone do not
+// reflect it. The stories only found on a section page have ProminenceSection and no rank.
+func (c *CategorizedClient) GetHeadlines(ctx context.Context) (Response, error) {
+	response, err := c.client.GetHeadlines(ctx)
+	if err != nil {
+		response.Source = c.SourceInfo()
+		return response, err
+	}
+
+	index := make(map[string]int)
+	var items []NewsItem
+	add := func(item NewsItem, category string, section bool) {
+		if category == "" {
+			category = CategoryFromURL(item.URL)
+		}
+		if i, ok := index[item.URL]; ok {
+			if items[i].Category == "" {
+				items[i].Category = category
+			}
+			return
+		}
+		item.Category = category
+		if section {
+			// Stories only found on a section page are not on the front page
+			item.Rank, item.Prominence = 0, ProminenceSection
+		}
+		index[item.URL] = len(items)
+		items = append(items, item)
+	}
+
+	for _, item := range response.Headlines {
+		add(item, item.Category, false)
+	}
+
+	for _, section := range c.sections {
+		sectionResponse, sectionErr := section.Client.GetHeadlines(ctx)
+		if sectionErr != nil {
+			log.Printf("Error fetching %s headlines from %s: %v", section.Category, c.SourceInfo().Name, sectionErr)
+			continue
+		}
+		for _, item := range sectionResponse.Headlines {
+			add(item, section.Category, true)
+		}
+	}
+
+	response.Source = c.SourceInfo()
+	response.Headlines = items
+	return response, nil
+}
+
+// FilterCategory returns the responses with only the headlines of the given category
+func FilterCategory(responses []Response, category string) []Response {
+	filtered := make([]Response, len(responses))
+	for i, response := range responses {
+		filtered[i] = response
+		filtered[i].Headlines = []NewsItem{}
+		for _, item := range response.Headlines {
+			if item.Category == category {
+				filtered[i].Headlines = append(filtered[i].Headlines, item)
+			}
+		}
+	}
+	return filtered
+}
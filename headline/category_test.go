@@ -0,0 +1,97 @@
+package headline
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeCategory(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{"sports", CategorySports},
+		{" Sports ", CategorySports},
+		{"খেলা", CategorySports},
+		{"world", CategoryInternational},
+		{"আন্তর্জাতিক", CategoryInternational},
+		{"জাতীয়", CategoryNational},
+		{"tech-startup", CategoryTechnology},
+		{"অর্থনীতি", CategoryBusiness},
+		{"weather", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := NormalizeCategory(tc.name); got != tc.expected {
+			t.Errorf("NormalizeCategory(%q) = %q; want %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestCategoryFromURL(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"https://www.prothomalo.com/sports/cricket/some-story", CategorySports},
+		{"https://bangla.thedailystar.net/news/bangladesh/politics/news-123", CategoryNational},
+		{"https://mzamin.com/news.php?news=1", ""},
+		{"https://www.prothomalo.com/sports", ""},
+	}
+
+	for _, tc := range testCases {
+		if got := CategoryFromURL(tc.url); got != tc.expected {
+			t.Errorf("CategoryFromURL(%q) = %q; want %q", tc.url, got, tc.expected)
+		}
+	}
+}
+
+func TestCategorizedClient(t *testing.T) {
+	home := &FlakyNewsClient{MockNewsClient: MockNewsClient{headlines: []NewsItem{
+		{Title: "Home 1", URL: "http://test.com/world/1", Rank: 1},
+		{Title: "Home 2", URL: "http://test.com/2", Rank: 2},
+	}}}
+	sports := &MockNewsClient{headlines: []NewsItem{
+		{Title: "Home 2", URL: "http://test.com/2"},
+		{Title: "Sports 1", URL: "http://test.com/3"},
+	}}
+	failing := &FlakyNewsClient{err: errors.New("timeout")}
+
+	client, err := NewCategorizedClient(home, []Section{
+		{Category: "খেলা", Client: sports},
+		{Category: "politics", Client: failing},
+	})
+	if err != nil {
+		t.Fatalf("Error creating client: %v", err)
+	}
+
+	response, err := client.GetHeadlines(context.Background())
+	if err != nil {
+		t.Fatalf("Expected a failing section to be skipped, got %v", err)
+	}
+	expected := []NewsItem{
+		{Title: "Home 1", URL: "http://test.com/world/1", Category: CategoryInternational, Rank: 1},
+		{Title: "Home 2", URL: "http://test.com/2", Category: CategorySports, Rank: 2},
+		{Title: "Sports 1", URL: "http://test.com/3", Category: CategorySports, Prominence: ProminenceSection},
+	}
+	if !reflect.DeepEqual(response.Headlines, expected) {
+		t.Errorf("Expected %v, got %v", expected, response.Headlines)
+	}
+
+	// A failing homepage fails the source, so that the sections alone are not served as its front page
+	home.err = errors.New("timeout")
+	if response, err = client.GetHeadlines(context.Background()); !errors.Is(err, home.err) || len(response.Headlines) != 0 {
+		t.Errorf("Expected the homepage error, got %v, %v", response.Headlines, err)
+	}
+
+	if _, err := NewCategorizedClient(home, []Section{{Category: "weather", Client: sports}}); err == nil {
+		t.Error("Expected an error for an unknown category")
+	}
+
+	filtered := FilterCategory([]Response{{Headlines: expected}}, CategorySports)
+	if len(filtered[0].Headlines) != 2 {
+		t.Errorf("Expected 2 sports headlines, got %v", filtered[0].Headlines)
+	}
+}
@@ -1,21 +1,31 @@
 package headline
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"strings"
 
 	"golang.org/x/net/html"
 )
 
+func init() {
+	Register("dailystarbangla", func(opts Options) (NewsClient, error) {
+		client := NewDailyStarBanglaClient(orDefault(opts.URL, "https://bangla.thedailystar.net/"), opts.HTTPClient)
+		client.ID = opts.ID
+		return client, nil
+	})
+}
+
 // DailyStarBanglaClient is a client to fetch headlines from bangla.thedailystar.net
 type DailyStarBanglaClient struct {
+	// ID is the configured ID of the source, dailystarbangla when empty
+	ID         string
 	URL        string
-	HTTPClient *CachingHTTPClient
+	HTTPClient Fetcher
 }
 
 // NewDailyStarBanglaClient creates a new DailyStarBanglaClient
-func NewDailyStarBanglaClient(url string, client *CachingHTTPClient) *DailyStarBanglaClient {
+func NewDailyStarBanglaClient(url string, client Fetcher) *DailyStarBanglaClient {
 	return &DailyStarBanglaClient{
 		URL:        url,
 		HTTPClient: client,
@@ -25,6 +35,7 @@ func NewDailyStarBanglaClient(url string, client *CachingHTTPClient) *DailyStarB
 // SourceInfo returns information about the news source
 func (c *DailyStarBanglaClient) SourceInfo() SourceInfo {
 	return SourceInfo{
+		ID:       orDefault(c.ID, "dailystarbangla"),
 		Name:     "Daily Star Bangla",
 		Logo:     "https://bangla.thedailystar.net/sites/all/themes/sloth/logo-bn.png",
 		Homepage: "https://bangla.thedailystar.net/",
@@ -32,21 +43,13 @@ func (c *DailyStarBanglaClient) SourceInfo() SourceInfo {
 }
 
 // GetHeadlines fetches the headlines from bangla.thedailystar.net
-func (c *DailyStarBanglaClient) GetHeadlines() (Response, error) {
-	resp, err := c.HTTPClient.Get(c.URL)
+func (c *DailyStarBanglaClient) GetHeadlines(ctx context.Context) (Response, error) {
+	page, err := fetchPage(ctx, c.HTTPClient, c.URL)
 	if err != nil {
-		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to fetch the website: %v", err)
+		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to fetch the website: %w", err)
 	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(resp.Body)
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to read the response body: %v", err)
-	}
-
-	headlines, err := c.extractDailyStarBanglaHeadlines(string(body), c.URL)
+	headlines, err := c.ExtractHeadlines(string(page.Body), nil)
 	if err != nil {
 		return Response{Source: c.SourceInfo()}, err
 	}
@@ -57,7 +60,9 @@ func (c *DailyStarBanglaClient) GetHeadlines() (Response, error) {
 	}, nil
 }
 
-func (c *DailyStarBanglaClient) extractDailyStarBanglaHeadlines(htmlContent, baseURL string) ([]NewsItem, error) {
+// ExtractHeadlines extracts the headlines from the top and category sections of the front page
+func (c *DailyStarBanglaClient) ExtractHeadlines(htmlContent string, trace Tracer) ([]NewsItem, error) {
+	baseURL := c.URL
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %v", err)
@@ -68,9 +73,10 @@ func (c *DailyStarBanglaClient) extractDailyStarBanglaHeadlines(htmlContent, bas
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "div" {
 			class := getAttr(n, "class")
-			if strings.Contains(class, "panel-pane pane-home-top-v7 no-title block") ||
-				strings.Contains(class, "panel-pane pane-category-news no-title block") {
-				c.extractHeadlinesFromSection(n, &headlines, baseURL)
+			if strings.Contains(class, "panel-pane pane-home-top-v7 no-title block") {
+				c.extractHeadlinesFromSection(n, &headlines, baseURL, ProminenceTop, trace)
+			} else if strings.Contains(class, "panel-pane pane-category-news no-title block") {
+				c.extractHeadlinesFromSection(n, &headlines, baseURL, ProminenceRegular, trace)
 			}
 		}
 		for child := n.FirstChild; child != nil; child = child.NextSibling {
@@ -79,17 +85,24 @@ func (c *DailyStarBanglaClient) extractDailyStarBanglaHeadlines(htmlContent, bas
 	}
 	f(doc)
 
-	return headlines, nil
+	// The first story of the top section is the lead
+	if len(headlines) > 0 && headlines[0].Prominence == ProminenceTop {
+		headlines[0].Prominence = ProminenceLead
+	}
+	return rankItems(headlines), nil
 }
 
-// extractHeadlinesFromSection extracts headlines from a section of the page
-func (c *DailyStarBanglaClient) extractHeadlinesFromSection(n *html.Node, headlines *[]NewsItem, baseURL string) {
+// extractHeadlinesFromSection extracts headlines with the given prominence from a section of the page
+func (c *DailyStarBanglaClient) extractHeadlinesFromSection(n *html.Node, headlines *[]NewsItem, baseURL, prominence string, trace Tracer) {
+	rule := "div.card-content > h3.title > a"
 	var f func(*html.Node)
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "div" && strings.Contains(getAttr(n, "class"), "card-content") {
 			var title, url string
+			found := false
 			for child := n.FirstChild; child != nil; child = child.NextSibling {
 				if child.Type == html.ElementNode && child.Data == "h3" && getAttr(child, "class") == "title" {
+					found = true
 					for a := child.FirstChild; a != nil; a = a.NextSibling {
 						if a.Type == html.ElementNode && a.Data == "a" {
 							title = extractText(a)
@@ -97,15 +110,19 @@ func (c *DailyStarBanglaClient) extractHeadlinesFromSection(n *html.Node, headli
 							break
 						}
 					}
-					if title != "" && url != "" {
+					if trace.candidate(child, rule, title, url) {
 						*headlines = append(*headlines, NewsItem{
-							Title: title,
-							URL:   completeURL(baseURL, url),
+							Title:      title,
+							URL:        completeURL(baseURL, url),
+							Prominence: prominence,
 						})
 					}
 					break
 				}
 			}
+			if !found {
+				trace.rejectNode(n, rule, "no h3.title child")
+			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
 			f(c)
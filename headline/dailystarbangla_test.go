@@ -1,6 +1,7 @@
 package headline
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -39,7 +40,7 @@ func TestDailyStarBanglaClient_GetHeadlines(t *testing.T) {
 	client := NewDailyStarBanglaClient(server.URL, NewCachingHTTPClient(0, "test-agent"))
 
 	// Get headlines
-	response, err := client.GetHeadlines()
+	response, err := client.GetHeadlines(context.Background())
 	if err != nil {
 		t.Fatalf("Error getting headlines: %v", err)
 	}
@@ -50,11 +51,12 @@ func TestDailyStarBanglaClient_GetHeadlines(t *testing.T) {
 	}
 
 	expectedHeadlines := []struct {
-		title string
-		url   string
+		title      string
+		url        string
+		prominence string
 	}{
-		{"Headline 1", server.URL + "/article1"},
-		{"Headline 2", server.URL + "/article2"},
+		{"Headline 1", server.URL + "/article1", ProminenceLead},
+		{"Headline 2", server.URL + "/article2", ProminenceRegular},
 	}
 
 	for i, expected := range expectedHeadlines {
@@ -70,10 +72,15 @@ func TestDailyStarBanglaClient_GetHeadlines(t *testing.T) {
 		if response.Headlines[i].URL != expected.url {
 			t.Errorf("Expected headline URL '%s', got '%s'", expected.url, response.Headlines[i].URL)
 		}
+
+		if response.Headlines[i].Rank != i+1 || response.Headlines[i].Prominence != expected.prominence {
+			t.Errorf("Expected rank %d and prominence %s, got %d and %s", i+1, expected.prominence, response.Headlines[i].Rank, response.Headlines[i].Prominence)
+		}
 	}
 
 	// Check source info
 	expectedSourceInfo := SourceInfo{
+		ID:       "dailystarbangla",
 		Name:     "Daily Star Bangla",
 		Logo:     "https://bangla.thedailystar.net/sites/all/themes/sloth/logo-bn.png",
 		Homepage: "https://bangla.thedailystar.net/",
@@ -89,6 +96,7 @@ func TestDailyStarBanglaClient_SourceInfo(t *testing.T) {
 	info := client.SourceInfo()
 
 	expectedInfo := SourceInfo{
+		ID:       "dailystarbangla",
 		Name:     "Daily Star Bangla",
 		Logo:     "https://bangla.thedailystar.net/sites/all/themes/sloth/logo-bn.png",
 		Homepage: "https://bangla.thedailystar.net/",
@@ -0,0 +1,349 @@
+package headline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Embedded JSON locations understood by EmbeddedJSON.Script
+const (
+	// ScriptNextData is the <script id="__NEXT_DATA__"> block of Next.js sites
+	ScriptNextData = "next_data"
+	// ScriptInitialState is the window.__INITIAL_STATE__ assignment
+	ScriptInitialState = "initial_state"
+	// ScriptLDJSON are all <script type="application/ld+json"> blocks
+	ScriptLDJSON = "ld+json"
+)
+
+func init() {
+	Register("embedded-json", func(opts Options) (NewsClient, error) {
+		if opts.URL == "" {
+			return nil, errors.New("url is required")
+		}
+		extractor := EmbeddedJSONFromParams(opts.Params, EmbeddedJSON{})
+		if extractor.Script == "" || extractor.Items == "" || extractor.Title == "" || extractor.URL == "" {
+			return nil, errors.New("options script, items, title and url_path are required")
+		}
+		info := SourceInfo{
+			ID:       orDefault(opts.ID, "embedded-json"),
+			Name:     orDefault(opts.Params["name"], opts.URL),
+			Logo:     opts.Params["logo"],
+			Homepage: orDefault(opts.Params["homepage"], opts.URL),
+		}
+		return NewEmbeddedJSONClient(opts.URL, opts.HTTPClient, info, extractor), nil
+	})
+}
+
+// EmbeddedJSON describes how to locate the JSON state embedded in a page and map it to NewsItems.
+//
+// Paths are dot separated keys. A [*] suffix iterates an array, [N] picks an element and *
+// iterates the values of an object in key order, e.g. "props.pageProps.stories[*]". Title and URL are
+// evaluated relative to each item and may list alternatives separated by |, the first
+// non-empty string wins.
+type EmbeddedJSON struct {
+	// Script is one of ScriptNextData, ScriptInitialState or ScriptLDJSON, "#id" for the
+	// <script> element with that ID, or "window.name" for a JavaScript assignment
+	Script string
+	// Items is the path to the story objects
+	Items string
+	// Title is the path to the headline of a story
+	Title string
+	// URL is the path to the link of a story
+	URL string
+}
+
+// EmbeddedJSONFromParams overrides the defaults with the script, items, title and url_path params
+func EmbeddedJSONFromParams(params map[string]string, defaults EmbeddedJSON) EmbeddedJSON {
+	e := defaults
+	e.Script = orDefault(params["script"], e.Script)
+	e.Items = orDefault(params["items"], e.Items)
+	e.Title = orDefault(params["title"], e.Title)
+	e.URL = orDefault(params["url_path"], e.URL)
+	return e
+}
+
+// Extract parses the embedded JSON of the page and maps it to NewsItems with URLs resolved against baseURL
+func (e EmbeddedJSON) Extract(htmlContent, baseURL string) ([]NewsItem, error) {
+	return e.ExtractTraced(htmlContent, baseURL, nil)
+}
+
+// ExtractTraced is Extract reporting every story object found at the items path to trace.
+// JSON-LD blocks that do not parse, such as those of third-party widgets, are skipped unless no
+// block yields any items.
+func (e EmbeddedJSON) ExtractTraced(htmlContent, baseURL string, trace Tracer) ([]NewsItem, error) {
+	doc, err := html.Parse(strings.NewReader(htmlContent))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %v", err)
+	}
+
+	blocks := e.locate(doc)
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("embedded JSON %q not found", e.Script)
+	}
+
+	var items []NewsItem
+	var parseErr error
+	seen := make(map[string]bool)
+	rule := fmt.Sprintf("title %s, url %s", e.Title, e.URL)
+	for b, block := range blocks {
+		var data any
+		if err := json.Unmarshal([]byte(block), &data); err != nil {
+			err = fmt.Errorf("failed to parse embedded JSON %q: %v", e.Script, err)
+			if e.Script != ScriptLDJSON {
+				return nil, err
+			}
+			trace.reject(fmt.Sprintf("%s block %d", e.Script, b+1), rule, err.Error(), "", "")
+			if parseErr == nil {
+				parseErr = err
+			}
+			continue
+		}
+
+		for i, item := range evalJSONPath(data, e.Items) {
+			title := normalizeText(firstString(item, e.Title))
+			url := completeURL(baseURL, firstString(item, e.URL))
+			path := fmt.Sprintf("%s block %d: %s #%d", e.Script, b+1, e.Items, i)
+			switch {
+			case title == "":
+				trace.reject(path, rule, "empty title", title, url)
+			case url == "":
+				trace.reject(path, rule, "missing url", title, url)
+			case seen[url]:
+				trace.reject(path, rule, "duplicate url", title, url)
+			default:
+				if trace != nil {
+					trace(TraceEvent{Path: path, Rule: rule, Outcome: TraceMatched, Title: title, URL: url})
+				}
+				seen[url] = true
+				items = append(items, NewsItem{Title: title, URL: url})
+			}
+		}
+	}
+	if len(items) == 0 && parseErr != nil {
+		return nil, parseErr
+	}
+	return items, nil
+}
+
+// locate returns the raw JSON blocks of the page matching Script
+func (e EmbeddedJSON) locate(doc *html.Node) []string {
+	var blocks []string
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "script" && n.FirstChild != nil {
+			text := n.FirstChild.Data
+			switch {
+			case e.Script == ScriptNextData:
+				if getAttr(n, "id") == "__NEXT_DATA__" {
+					blocks = append(blocks, text)
+				}
+			case e.Script == ScriptLDJSON:
+				if getAttr(n, "type") == "application/ld+json" {
+					blocks = append(blocks, text)
+				}
+			case strings.HasPrefix(e.Script, "#"):
+				if getAttr(n, "id") == e.Script[1:] {
+					blocks = append(blocks, text)
+				}
+			default:
+				variable := e.Script
+				if variable == ScriptInitialState {
+					variable = "window.__INITIAL_STATE__"
+				}
+				if block, ok := assignedJSON(text, variable); ok {
+					blocks = append(blocks, block)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+	return blocks
+}
+
+// assignedJSON returns the JSON object or array literal assigned to variable in a script,
+// e.g. window.__INITIAL_STATE__ = {...};
+func assignedJSON(script, variable string) (string, bool) {
+	i := strings.Index(script, variable)
+	if i < 0 {
+		return "", false
+	}
+	rest := strings.TrimLeft(script[i+len(variable):], " \t\r\n")
+	if !strings.HasPrefix(rest, "=") {
+		return "", false
+	}
+	rest = strings.TrimLeft(rest[1:], " \t\r\n")
+
+	// Some sites assign JSON.parse("...") instead of a literal
+	if strings.HasPrefix(rest, "JSON.parse(") {
+		var literal string
+		if err := json.NewDecoder(strings.NewReader(rest[len("JSON.parse("):])).Decode(&literal); err != nil {
+			return "", false
+		}
+		return literal, true
+	}
+
+	end := matchingBracket(rest)
+	if end < 0 {
+		return "", false
+	}
+	return rest[:end+1], true
+}
+
+// matchingBracket returns the index of the bracket closing the object or array that s starts with
+func matchingBracket(s string) int {
+	if s == "" || (s[0] != '{' && s[0] != '[') {
+		return -1
+	}
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// evalJSONPath evaluates a path against decoded JSON and returns all matching values
+func evalJSONPath(data any, path string) []any {
+	values := []any{data}
+	if path == "" {
+		return values
+	}
+
+	for _, segment := range strings.Split(path, ".") {
+		key, index, hasIndex := strings.Cut(segment, "[")
+		index = strings.TrimSuffix(index, "]")
+
+		var next []any
+		for _, v := range values {
+			if key == "*" {
+				if obj, ok := v.(map[string]any); ok {
+					keys := make([]string, 0, len(obj))
+					for k := range obj {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						next = append(next, obj[k])
+					}
+				}
+				continue
+			}
+			if key != "" {
+				obj, ok := v.(map[string]any)
+				if !ok {
+					continue
+				}
+				if v, ok = obj[key]; !ok {
+					continue
+				}
+			}
+			if !hasIndex {
+				next = append(next, v)
+				continue
+			}
+			arr, ok := v.([]any)
+			if !ok {
+				continue
+			}
+			if index == "*" {
+				next = append(next, arr...)
+			} else if n, err := strconv.Atoi(index); err == nil && n >= 0 && n < len(arr) {
+				next = append(next, arr[n])
+			}
+		}
+		values = next
+	}
+	return values
+}
+
+// firstString returns the first non-empty string found at one of the |-separated paths
+func firstString(data any, paths string) string {
+	for _, path := range strings.Split(paths, "|") {
+		for _, v := range evalJSONPath(data, strings.TrimSpace(path)) {
+			if s, ok := v.(string); ok && strings.TrimSpace(s) != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// EmbeddedJSONClient is a client to fetch headlines from the JSON state embedded in a page
+type EmbeddedJSONClient struct {
+	URL        string
+	HTTPClient Fetcher
+	Info       SourceInfo
+	Extractor  EmbeddedJSON
+}
+
+// NewEmbeddedJSONClient creates a new EmbeddedJSONClient
+func NewEmbeddedJSONClient(url string, client Fetcher, info SourceInfo, extractor EmbeddedJSON) *EmbeddedJSONClient {
+	return &EmbeddedJSONClient{
+		URL:        url,
+		HTTPClient: client,
+		Info:       info,
+		Extractor:  extractor,
+	}
+}
+
+// SourceInfo returns information about the news source
+func (c *EmbeddedJSONClient) SourceInfo() SourceInfo {
+	return c.Info
+}
+
+// GetHeadlines fetches the page and extracts the headlines from its embedded JSON
+func (c *EmbeddedJSONClient) GetHeadlines(ctx context.Context) (Response, error) {
+	page, err := fetchPage(ctx, c.HTTPClient, c.URL)
+	if err != nil {
+		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to fetch the website: %w", err)
+	}
+
+	items, err := c.ExtractHeadlines(string(page.Body), nil)
+	if err != nil {
+		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to extract news items: %v", err)
+	}
+
+	return Response{
+		Source:    c.SourceInfo(),
+		Headlines: items,
+	}, nil
+}
+
+// ExtractHeadlines extracts the headlines from the embedded JSON of a page
+func (c *EmbeddedJSONClient) ExtractHeadlines(htmlContent string, trace Tracer) ([]NewsItem, error) {
+	items, err := c.Extractor.ExtractTraced(htmlContent, c.URL, trace)
+	if err != nil {
+		return nil, err
+	}
+	return rankItems(items), nil
+}
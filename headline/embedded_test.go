@@ -0,0 +1,135 @@
+package headline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedJSONExtract(t *testing.T) {
+	testCases := []struct {
+		name      string
+		html      string
+		extractor EmbeddedJSON
+	}{
+		{
+			name: "next data",
+			html: `<html><body><script id="__NEXT_DATA__" type="application/json">
+				{"props":{"pageProps":{"stories":[
+					{"headline":"Test Headline","slug":"/news/test-headline"},
+					{"headline":"","slug":"/news/empty"},
+					{"headline":"Another Headline","slug":"/news/another-headline"}
+				]}}}</script></body></html>`,
+			extractor: EmbeddedJSON{Script: ScriptNextData, Items: "props.pageProps.stories[*]", Title: "headline", URL: "slug"},
+		},
+		{
+			// Object values are iterated in key order and duplicate URLs are dropped
+			name: "initial state",
+			html: `<html><head><script>
+				var x = 1;
+				window.__INITIAL_STATE__ = {"collections":{"b":{"items":[{"title":"Another Headline","url":"/news/another-headline"}]},
+					"a":{"items":[{"title":"Test Headline","url":"/news/test-headline"},{"title":"Test Headline","url":"/news/test-headline"}]}}};
+				window.other = {"ignored": "}"};
+			</script></head></html>`,
+			extractor: EmbeddedJSON{Script: ScriptInitialState, Items: "collections.*.items[*]", Title: "title", URL: "url"},
+		},
+		{
+			name: "ld+json",
+			html: `<html><head>
+				<script type="application/ld+json">{"@type":"Organization","name":"Test"}</script>
+				<script type="application/ld+json">{"@type":"ItemList","itemListElement":[
+					{"item":{"name":"Test Headline","url":"/news/test-headline"}},
+					{"name":"Another Headline","url":"/news/another-headline"}
+				]}</script></head></html>`,
+			extractor: EmbeddedJSON{Script: ScriptLDJSON, Items: "itemListElement[*]", Title: "item.name|name", URL: "item.url|url"},
+		},
+		{
+			// A broken block, e.g. of a third-party widget, does not fail the page
+			name: "ld+json with a broken block",
+			html: `<html><head>
+				<script type="application/ld+json">{"@type":"WebSite","name":"Widget",}</script>
+				<script type="application/ld+json">{"@type":"ItemList","itemListElement":[
+					{"name":"Test Headline","url":"/news/test-headline"},
+					{"name":"Another Headline","url":"/news/another-headline"}
+				]}</script></head></html>`,
+			extractor: EmbeddedJSON{Script: ScriptLDJSON, Items: "itemListElement[*]", Title: "name", URL: "url"},
+		},
+		{
+			name:      "json parse assignment",
+			html:      `<script>window.qtState = JSON.parse("{\"stories\":[{\"h\":\"Test Headline\",\"u\":\"/news/test-headline\"},{\"h\":\"Another Headline\",\"u\":\"/news/another-headline\"}]}");</script>`,
+			extractor: EmbeddedJSON{Script: "window.qtState", Items: "stories[*]", Title: "h", URL: "u"},
+		},
+	}
+
+	expected := []NewsItem{
+		{Title: "Test Headline", URL: "http://example.com/news/test-headline"},
+		{Title: "Another Headline", URL: "http://example.com/news/another-headline"},
+	}
+
+	for _, tc := range testCases {
+		items, err := tc.extractor.Extract(tc.html, "http://example.com")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(items, expected) {
+			t.Errorf("%s: expected %v, got %v", tc.name, expected, items)
+		}
+	}
+
+	if _, err := (EmbeddedJSON{Script: ScriptNextData, Items: "stories[*]"}).Extract("<html></html>", "http://example.com"); err == nil {
+		t.Error("Expected an error when the embedded JSON is missing")
+	}
+	broken := `<script type="application/ld+json">{"@type":"ItemList",</script>`
+	if _, err := (EmbeddedJSON{Script: ScriptLDJSON, Items: "itemListElement[*]"}).Extract(broken, "http://example.com"); err == nil {
+		t.Error("Expected an error when no JSON-LD block parses")
+	}
+}
+
+func TestProthomAloClientEmbeddedMode(t *testing.T) {
+	page := `<html><body>
+		<script type="application/json" id="static-page">
+			{"qt":{"data":{"collection":{"items":[{"story":{"headline":"Embedded Headline","url":"https://www.prothomalo.com/news/embedded"}}]}}}}
+		</script>
+		<h3 class="headline-title"><a href="/news/markup"><span>Markup Headline</span></a></h3>
+	</body></html>`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/without-state" {
+			w.Write([]byte(`<h3 class="headline-title"><a href="/news/markup"><span>Markup Headline</span></a></h3>`))
+			return
+		}
+		w.Write([]byte(page))
+	}))
+	defer server.Close()
+
+	httpClient := NewCachingHTTPClient(time.Second, "test-agent")
+	client, err := New("prothomalo", Options{URL: server.URL, HTTPClient: httpClient, Params: map[string]string{"mode": "embedded"}})
+	if err != nil {
+		t.Fatalf("Error creating source: %v", err)
+	}
+
+	response, err := client.GetHeadlines(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting headlines: %v", err)
+	}
+	if len(response.Headlines) != 1 || response.Headlines[0].Title != "Embedded Headline" {
+		t.Errorf("Expected the embedded headline, got %v", response.Headlines)
+	}
+
+	// Without page state the markup is used
+	client, _ = New("prothomalo", Options{URL: server.URL + "/without-state", HTTPClient: httpClient, Params: map[string]string{"mode": "embedded"}})
+	response, err = client.GetHeadlines(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting headlines: %v", err)
+	}
+	if len(response.Headlines) != 1 || response.Headlines[0].Title != "Markup Headline" {
+		t.Errorf("Expected the markup headline as a fallback, got %v", response.Headlines)
+	}
+
+	if _, err := New("prothomalo", Options{Params: map[string]string{"mode": "unknown"}}); err == nil {
+		t.Error("Expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,84 @@
+package headline
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/unicode/norm"
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// toUTF8 transcodes a fetched body to UTF-8. A body that is entirely valid UTF-8 is kept as is,
+// even if its headers claim otherwise, since mis-declared charsets are common. Otherwise the
+// encoding is determined from the BOM, the Content-Type header and <meta charset>, in that order.
+// Non-textual content is returned unchanged.
+func toUTF8(body []byte, contentType string) ([]byte, error) {
+	if !isTextual(contentType) {
+		return body, nil
+	}
+
+	if bytes.HasPrefix(body, utf8BOM) {
+		return body[len(utf8BOM):], nil
+	}
+	if utf8.Valid(body) {
+		return body, nil
+	}
+
+	enc, _, _ := charset.DetermineEncoding(body, contentType)
+	decoded, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimPrefix(decoded, utf8BOM), nil
+}
+
+func isTextual(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.Contains(mediaType, "html") ||
+		strings.Contains(mediaType, "xml") ||
+		strings.Contains(mediaType, "json")
+}
+
+// normalizeText normalizes scraped text to Unicode NFC, removes invisible characters and
+// collapses whitespace. The zero-width joiner and non-joiner are kept because they are
+// meaningful in Bengali script.
+func normalizeText(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	space := false
+	for _, r := range s {
+		switch {
+		case r == '\u200b' || r == '\u2060' || r == '\ufeff' || r == '\u00ad':
+			continue
+		case unicode.IsSpace(r):
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+
+	return norm.NFC.String(b.String())
+}
+
+// DecodeHTML transcodes a page that was not fetched, such as a saved file, to UTF-8 as fetched
+// pages are
+func DecodeHTML(body []byte) ([]byte, error) {
+	return toUTF8(body, "text/html")
+}
@@ -0,0 +1,82 @@
+package headline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestToUTF8(t *testing.T) {
+	latin1, _ := charmap.ISO8859_1.NewEncoder().String("<h3>Café</h3>")
+	utf16, _ := unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewEncoder().String("<h3>খবর</h3>")
+
+	testCases := []struct {
+		name        string
+		body        string
+		contentType string
+		expected    string
+	}{
+		{"utf-8", "<h3>খবর</h3>", "text/html; charset=utf-8", "<h3>খবর</h3>"},
+		{"mis-declared utf-8", "<h3>খবর</h3>", "text/html; charset=iso-8859-1", "<h3>খবর</h3>"},
+		{"utf-8 bom", "\xEF\xBB\xBF<h3>খবর</h3>", "text/html", "<h3>খবর</h3>"},
+		{"header charset", latin1, "text/html; charset=iso-8859-1", "<h3>Café</h3>"},
+		{"meta charset", `<meta charset="windows-1252">` + latin1, "text/html", `<meta charset="windows-1252"><h3>Café</h3>`},
+		{"utf-16 bom", utf16, "text/html", "<h3>খবর</h3>"},
+		{"binary", "\xff\xfe\x00", "image/png", "\xff\xfe\x00"},
+	}
+
+	for _, tc := range testCases {
+		got, err := toUTF8([]byte(tc.body), tc.contentType)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if string(got) != tc.expected {
+			t.Errorf("%s: toUTF8() = %q; want %q", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestNormalizeText(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"  Test \n\t Headline  ", "Test Headline"},
+		{"Test\u00a0Headline", "Test Headline"},
+		{"Te\u200bst\ufeff Head\u00adline", "Test Headline"},
+		// The decomposed vowel sign O is composed to U+09CB
+		{"\u0995\u09c7\u09be\u09a8", "\u0995\u09cb\u09a8"},
+		// The zero-width joiner of the ra-phala in RAB is kept
+		{"\u09b0\u200d\u09cd\u09af\u09be\u09ac", "\u09b0\u200d\u09cd\u09af\u09be\u09ac"},
+	}
+
+	for _, tc := range testCases {
+		if got := normalizeText(tc.input); got != tc.expected {
+			t.Errorf("normalizeText(%q) = %q; want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestCachingHTTPClientTranscodes(t *testing.T) {
+	body, _ := charmap.Windows1252.NewEncoder().String(`<h3 class="headline-title"><a href="/news/1"><span>Café   news</span></a></h3>`)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=windows-1252")
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	client := NewProthomAloClient(server.URL, NewCachingHTTPClient(time.Second, "test-agent"))
+	response, err := client.GetHeadlines(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting headlines: %v", err)
+	}
+	if len(response.Headlines) != 1 || response.Headlines[0].Title != "Café news" {
+		t.Errorf("Expected the transcoded title 'Café news', got %+v", response.Headlines)
+	}
+}
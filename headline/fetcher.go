@@ -0,0 +1,148 @@
+package headline
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Page is a fetched page
+type Page struct {
+	// URL is the URL the page was fetched from after following redirects
+	URL        string
+	StatusCode int
+	Header     http.Header
+	// Body is transcoded to UTF-8 for textual content
+	Body []byte
+}
+
+// Fetcher fetches pages. The news clients only depend on this interface, so that proxies, mocks
+// or tracing can be injected, and a Fetcher can be wrapped with Middleware.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) (*Page, error)
+}
+
+// FetcherFunc adapts a function to the Fetcher interface
+type FetcherFunc func(ctx context.Context, url string) (*Page, error)
+
+// Fetch calls f
+func (f FetcherFunc) Fetch(ctx context.Context, url string) (*Page, error) {
+	return f(ctx, url)
+}
+
+// fetchPage fetches the page of a news source. A page that was not served successfully fails
+// with a *StatusError, so that an error page is not parsed as a page without headlines.
+func fetchPage(ctx context.Context, f Fetcher, url string) (*Page, error) {
+	page, err := f.Fetch(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if page.StatusCode < 200 || page.StatusCode >= 300 {
+		return nil, &StatusError{URL: url, StatusCode: page.StatusCode}
+	}
+	return page, nil
+}
+
+// Middleware wraps a Fetcher with additional behaviour
+type Middleware func(Fetcher) Fetcher
+
+// Chain wraps f with the middleware. The first middleware is the outermost, so it sees every
+// fetch first and its result last.
+func Chain(f Fetcher, middleware ...Middleware) Fetcher {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		f = middleware[i](f)
+	}
+	return f
+}
+
+// LoggingMiddleware logs every fetch with its status or error and duration
+func LoggingMiddleware(logger *log.Logger) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, url string) (*Page, error) {
+			start := time.Now()
+			page, err := next.Fetch(ctx, url)
+			if err != nil {
+				logger.Printf("Fetch %s failed after %s: %v", url, time.Since(start).Round(time.Millisecond), err)
+			} else {
+				logger.Printf("Fetch %s: %d in %s", url, page.StatusCode, time.Since(start).Round(time.Millisecond))
+			}
+			return page, err
+		})
+	}
+}
+
+// FetchStats are the counters of the fetches to a host
+type FetchStats struct {
+	Requests int `json:"requests"`
+	Errors   int `json:"errors"`
+	// Statuses counts the responses by status code
+	Statuses map[int]int   `json:"statuses"`
+	Duration time.Duration `json:"duration"`
+}
+
+// FetchMetrics collects FetchStats per host, see MetricsMiddleware
+type FetchMetrics struct {
+	mu    sync.Mutex
+	hosts map[string]*FetchStats
+}
+
+// NewFetchMetrics creates empty FetchMetrics
+func NewFetchMetrics() *FetchMetrics {
+	return &FetchMetrics{hosts: make(map[string]*FetchStats)}
+}
+
+// Stats returns a copy of the counters per host
+func (m *FetchMetrics) Stats() map[string]FetchStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]FetchStats, len(m.hosts))
+	for host, s := range m.hosts {
+		copied := *s
+		copied.Statuses = make(map[int]int, len(s.Statuses))
+		for code, n := range s.Statuses {
+			copied.Statuses[code] = n
+		}
+		stats[host] = copied
+	}
+	return stats
+}
+
+func (m *FetchMetrics) record(host string, page *Page, err error, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.hosts[host]
+	if !ok {
+		s = &FetchStats{Statuses: make(map[int]int)}
+		m.hosts[host] = s
+	}
+	s.Requests++
+	s.Duration += d
+	if err != nil {
+		s.Errors++
+		return
+	}
+	s.Statuses[page.StatusCode]++
+}
+
+// MetricsMiddleware counts the fetches, their outcome and duration per host in m
+func MetricsMiddleware(m *FetchMetrics) Middleware {
+	return func(next Fetcher) Fetcher {
+		return FetcherFunc(func(ctx context.Context, rawURL string) (*Page, error) {
+			start := time.Now()
+			page, err := next.Fetch(ctx, rawURL)
+			m.record(hostOf(rawURL), page, err, time.Since(start))
+			return page, err
+		})
+	}
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
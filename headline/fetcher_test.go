@@ -0,0 +1,121 @@
+package headline
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCachingHTTPClientFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html>new</html>"))
+	}))
+	defer server.Close()
+
+	var fetcher Fetcher = NewCachingHTTPClient(time.Second, "test-agent")
+	page, err := fetcher.Fetch(context.Background(), server.URL+"/old")
+	if err != nil {
+		t.Fatalf("Error fetching page: %v", err)
+	}
+	if page.URL != server.URL+"/new" || page.StatusCode != http.StatusOK || page.Header.Get("Content-Type") != "text/html" || string(page.Body) != "<html>new</html>" {
+		t.Errorf("Unexpected page %+v", page)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := fetcher.Fetch(ctx, server.URL+"/other"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the canceled context to fail the fetch, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var calls []string
+	failure := errors.New("connection refused")
+	var fail bool
+	fake := FetcherFunc(func(ctx context.Context, url string) (*Page, error) {
+		calls = append(calls, url)
+		if fail {
+			return nil, failure
+		}
+		return &Page{URL: url, StatusCode: http.StatusOK, Body: []byte("<html></html>")}, nil
+	})
+
+	var logs bytes.Buffer
+	metrics := NewFetchMetrics()
+	fetcher := Chain(fake,
+		LoggingMiddleware(log.New(&logs, "", 0)),
+		MetricsMiddleware(metrics),
+	)
+
+	page, err := fetcher.Fetch(context.Background(), "http://example.com/")
+	if err != nil || page.StatusCode != http.StatusOK {
+		t.Fatalf("Expected the page, got %+v, %v", page, err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("Expected 1 call, got %d", len(calls))
+	}
+	stats := metrics.Stats()["example.com"]
+	if stats.Requests != 1 || stats.Errors != 0 || stats.Statuses[http.StatusOK] != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if !strings.Contains(logs.String(), "Fetch http://example.com/: 200") {
+		t.Errorf("Expected the fetch to be logged, got %q", logs.String())
+	}
+
+	fail = true
+	if _, err := fetcher.Fetch(context.Background(), "http://example.com/"); !errors.Is(err, failure) {
+		t.Errorf("Expected the error of the fetcher, got %v", err)
+	}
+	if stats := metrics.Stats()["example.com"]; stats.Requests != 2 || stats.Errors != 1 {
+		t.Errorf("Expected the failed fetch to be counted, got %+v", stats)
+	}
+	if !strings.Contains(logs.String(), "Fetch http://example.com/ failed") {
+		t.Errorf("Expected the failure to be logged, got %q", logs.String())
+	}
+}
+
+func TestClientWithFetcher(t *testing.T) {
+	fake := FetcherFunc(func(ctx context.Context, url string) (*Page, error) {
+		return &Page{URL: url, StatusCode: http.StatusOK, Body: []byte(`<html><body><h3><a href="/article1">Headline 1</a></h3></body></html>`)}, nil
+	})
+	response, err := NewMZaminClient("https://mzamin.com", fake).GetHeadlines(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting headlines: %v", err)
+	}
+	if len(response.Headlines) != 1 || response.Headlines[0].URL != "https://mzamin.com/article1" {
+		t.Errorf("Expected the headline of the fake page, got %+v", response.Headlines)
+	}
+}
+
+func TestClientsFailOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<html><body><h3><a href=\"/missing\">Not found</a></h3></body></html>", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := NewCachingHTTPClient(0, "test-agent")
+	clients := []NewsClient{
+		NewProthomAloClient(server.URL, client),
+		NewMZaminClient(server.URL, client),
+		NewDailyStarBanglaClient(server.URL, client),
+		NewEmbeddedJSONClient(server.URL, client, SourceInfo{ID: "embedded"}, ProthomAloEmbeddedJSON),
+	}
+	for _, c := range clients {
+		_, err := c.GetHeadlines(context.Background())
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected a StatusError with status 404 from %T, got %v", c, err)
+		}
+	}
+}
@@ -3,22 +3,21 @@ package headline
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"log"
+	"math/rand/v2"
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-var (
-	headlinesCache sync.Map
-	cacheDuration  = 1 * time.Minute
-)
-
 // NewsClient is an interface that defines the methods required to fetch news headlines
 type NewsClient interface {
-	GetHeadlines() (Response, error)
+	GetHeadlines(ctx context.Context) (Response, error)
 	SourceInfo() SourceInfo
 }
 
@@ -26,10 +25,18 @@ type NewsClient interface {
 type NewsItem struct {
 	Title string `json:"title"`
 	URL   string `json:"url"`
+	// Category is one of Categories(), or empty if the section of the item is unknown
+	Category string `json:"category,omitempty"`
+	// Rank is the 1-based position of the item on the scraped page
+	Rank int `json:"rank,omitempty"`
+	// Prominence is the tier of the item on the page, one of ProminenceLead, ProminenceTop or
+	// ProminenceRegular, or ProminenceSection for an item that is not on the front page
+	Prominence string `json:"prominence,omitempty"`
 }
 
 // SourceInfo represents information about the news source
 type SourceInfo struct {
+	ID       string `json:"id"`
 	Name     string `json:"name"`
 	Logo     string `json:"logo"`
 	Homepage string `json:"homepage"`
@@ -37,8 +44,43 @@ type SourceInfo struct {
 
 // Response represents the response from a news source
 type Response struct {
-	Source    SourceInfo `json:"source"`
-	Headlines []NewsItem `json:"headlines"`
+	Source    SourceInfo   `json:"source"`
+	Headlines []NewsItem   `json:"headlines"`
+	Error     *SourceError `json:"error,omitempty"`
+	// Stale is set when the headlines are the last successful ones because the source is failing
+	Stale bool `json:"stale,omitempty"`
+	// LastSuccessAt is when the headlines were successfully fetched
+	LastSuccessAt *time.Time `json:"lastSuccessAt,omitempty"`
+}
+
+// Current reports whether the headlines were fetched in this refresh, i.e. the response is
+// neither failed nor stale, and so reflect the current front page
+func (r Response) Current() bool {
+	return r.Error == nil && !r.Stale
+}
+
+// Error types reported in SourceError
+const (
+	ErrorTypeFetchFailed      = "fetch_failed"
+	ErrorTypeRobotsDisallowed = "robots_disallowed"
+	ErrorTypeCircuitOpen      = "circuit_open"
+)
+
+// SourceError describes why fetching the headlines of a source failed
+type SourceError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+func newSourceError(err error) *SourceError {
+	var robotsErr *RobotsDisallowedError
+	if errors.As(err, &robotsErr) {
+		return &SourceError{Type: ErrorTypeRobotsDisallowed, Message: err.Error()}
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return &SourceError{Type: ErrorTypeCircuitOpen, Message: err.Error()}
+	}
+	return &SourceError{Type: ErrorTypeFetchFailed, Message: err.Error()}
 }
 
 // CachedResponse represents a cached response
@@ -50,55 +92,188 @@ type CachedResponse struct {
 // CachingHTTPClient is an HTTP client that caches responses
 type CachingHTTPClient struct {
 	client    *http.Client
-	cache     sync.Map
+	cache     *sync.Map
+	cacheTTL  time.Duration
 	userAgent string
+	// userAgents are rotated through by the requests instead of userAgent, see WithUserAgents
+	userAgents    []string
+	nextUserAgent *atomic.Uint64
+
+	rateLimit *RateLimit
+	limiters  *sync.Map
+	now       func() time.Time
+	// sleep waits for the limiter and between retries, returning early when the context is done
+	sleep func(context.Context, time.Duration) error
+
+	robots       *robotsCache
+	ignoreRobots bool
+
+	retry  *RetryPolicy
+	random func() float64
+
+	// onPage is called with every page fetched successfully, see WithPageHook
+	onPage func(url string, body []byte)
+}
+
+// ClientOption configures a CachingHTTPClient
+type ClientOption func(*CachingHTTPClient)
+
+// WithCacheTTL sets how long responses are cached. A zero TTL caches responses forever.
+func WithCacheTTL(ttl time.Duration) ClientOption {
+	return func(c *CachingHTTPClient) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithPageHook returns a copy of the client that calls hook with the URL and the UTF-8 body of
+// every page it fetched successfully. Pages served from the cache are not passed to the hook.
+// The copy shares the cache, limiters and robots.txt rules of the client.
+func (c *CachingHTTPClient) WithPageHook(hook func(url string, body []byte)) *CachingHTTPClient {
+	clone := *c
+	clone.onPage = hook
+	return &clone
+}
+
+// cachedPage is a page in the cache of a CachingHTTPClient. Cached pages are shared and must not be modified.
+type cachedPage struct {
+	page     *Page
+	storedAt time.Time
 }
 
 // NewCachingHTTPClient creates a new CachingHTTPClient
-func NewCachingHTTPClient(timeout time.Duration, userAgent string) *CachingHTTPClient {
-	return &CachingHTTPClient{
+func NewCachingHTTPClient(timeout time.Duration, userAgent string, opts ...ClientOption) *CachingHTTPClient {
+	c := &CachingHTTPClient{
 		client: &http.Client{
 			Timeout: timeout,
 		},
+		cache:     &sync.Map{},
 		userAgent: userAgent,
+		limiters:  &sync.Map{},
+		now:       time.Now,
+		sleep:     sleepContext,
+		random:    rand.Float64,
 	}
-}
-
-// Get makes an HTTP GET request to the specified URL
-func (c *CachingHTTPClient) Get(url string) (*http.Response, error) {
-	if cachedBody, ok := c.cache.Load(url); ok {
-		return &http.Response{
-			StatusCode: http.StatusOK,
-			Body:       io.NopCloser(strings.NewReader(cachedBody.(string))),
-		}, nil
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// Fetch makes an HTTP GET request to the specified URL.
+// Only successful responses are cached. When robots.txt is honoured, disallowed URLs fail
+// with a *RobotsDisallowedError. When rate limiting is enabled, requests wait for their
+// host's limiter, and a 429 or 503 response with a Retry-After header blocks the host for the
+// given delay and is retried once if the delay is short enough. When retries are enabled,
+// network errors and temporary failures are retried with exponential backoff. A response that
+// still indicates a temporary failure is returned as a *StatusError. Textual bodies are
+// transcoded to UTF-8. Waiting for the limiter or a retry ends with the error of ctx when it is
+// done. Pages served from the cache are shared and must not be modified.
+func (c *CachingHTTPClient) Fetch(ctx context.Context, url string) (*Page, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("User-Agent", c.userAgent)
+	req.Header.Set("User-Agent", c.requestUserAgent())
 
-	resp, err := c.client.Do(req)
+	// robots.txt is consulted before the cache, which is shared with the clients ignoring it
+	allowed, err := c.robotsAllowed(ctx, req.URL)
 	if err != nil {
 		return nil, err
 	}
+	if !allowed {
+		return nil, &RobotsDisallowedError{URL: url}
+	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		resp.Body.Close()
-		return nil, err
+	if cached, ok := c.cache.Load(url); ok {
+		entry := cached.(cachedPage)
+		if c.cacheTTL == 0 || c.now().Sub(entry.storedAt) < c.cacheTTL {
+			return entry.page, nil
+		}
+		c.cache.Delete(url)
 	}
-	resp.Body.Close()
 
-	c.cache.Store(url, string(body))
+	limiter := c.limiter(req.URL.Host)
+	retriedAfter := false
+	for attempt := 1; ; attempt++ {
+		resp, body, err := c.do(req, limiter)
 
+		if err == nil && limiter != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+			if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), c.now()); ok {
+				limiter.block(c.now().Add(delay))
+				if !retriedAfter && delay <= c.rateLimit.MaxRetryAfter {
+					retriedAfter = true
+					continue
+				}
+				return nil, &StatusError{URL: url, StatusCode: resp.StatusCode, RetryAfter: delay}
+			}
+		}
+
+		if c.retry != nil && attempt < c.retry.MaxAttempts && retryable(resp, err) {
+			if err := c.sleep(ctx, c.retry.backoff(attempt, c.random)); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+		if c.retry != nil && temporaryStatus(resp.StatusCode) {
+			return nil, &StatusError{URL: url, StatusCode: resp.StatusCode}
+		}
+
+		body, err = toUTF8(body, resp.Header.Get("Content-Type"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the response body: %w", err)
+		}
+
+		page := &Page{URL: resp.Request.URL.String(), StatusCode: resp.StatusCode, Header: resp.Header, Body: body}
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			c.cache.Store(url, cachedPage{page: page, storedAt: c.now()})
+			if c.onPage != nil {
+				c.onPage(url, body)
+			}
+		}
+		return page, nil
+	}
+}
+
+// Get is Fetch without a context, returning the page as an *http.Response
+func (c *CachingHTTPClient) Get(url string) (*http.Response, error) {
+	page, err := c.Fetch(context.Background(), url)
+	if err != nil {
+		return nil, err
+	}
 	return &http.Response{
-		StatusCode: resp.StatusCode,
-		Body:       io.NopCloser(bytes.NewReader(body)),
+		StatusCode: page.StatusCode,
+		Header:     page.Header,
+		Body:       io.NopCloser(bytes.NewReader(page.Body)),
 	}, nil
 }
 
+// do sends the request once the host's limiter allows it and reads the whole body
+func (c *CachingHTTPClient) do(req *http.Request, limiter *hostLimiter) (*http.Response, []byte, error) {
+	if limiter != nil {
+		release, err := limiter.acquire(req.Context(), c.now, c.sleep)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer release()
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
 func completeURL(baseURL, relativeURL string) string {
 	if relativeURL == "" {
 		return ""
@@ -112,45 +287,3 @@ func completeURL(baseURL, relativeURL string) string {
 	}
 	return baseURL + "/" + relativeURL
 }
-
-// GetHeadlines fetches headlines from the specified news sources
-func GetHeadlines(sources []NewsClient) []Response {
-	var wg sync.WaitGroup
-	results := make([]Response, len(sources))
-
-	for i, source := range sources {
-		wg.Add(1)
-		go func(index int, s NewsClient) {
-			defer wg.Done()
-			items, err := s.GetHeadlines()
-			if err != nil {
-				log.Printf("Error fetching headlines from %s: %v", s.SourceInfo().Name, err)
-				results[index] = Response{Source: s.SourceInfo(), Headlines: nil}
-			} else {
-				results[index] = items
-			}
-		}(i, source)
-	}
-
-	wg.Wait()
-	return results
-}
-
-// GetCachedHeadlines returns cached headlines if they are not expired
-func GetCachedHeadlines() ([]Response, bool) {
-	if cachedResp, ok := headlinesCache.Load("headlines"); ok {
-		cached := cachedResp.(CachedResponse)
-		if time.Since(cached.Timestamp) < cacheDuration {
-			return cached.Body, true
-		}
-	}
-	return nil, false
-}
-
-// CacheHeadlines caches the provided headlines
-func CacheHeadlines(headlines []Response) {
-	headlinesCache.Store("headlines", CachedResponse{
-		Body:      headlines,
-		Timestamp: time.Now(),
-	})
-}
@@ -1,72 +1,13 @@
 package headline
 
 import (
-	"reflect"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
 
-func TestGetAndCacheHeadlines(t *testing.T) {
-	// Clear the cache before testing
-	headlinesCache.Range(func(key, value interface{}) bool {
-		headlinesCache.Delete(key)
-		return true
-	})
-
-	testHeadlines := []Response{
-		{
-			Source: SourceInfo{Name: "Test Source", Logo: "http://example.com/logo.png", Homepage: "http://example.com"},
-			Headlines: []NewsItem{
-				{Title: "Test Headline 1", URL: "http://example.com/1"},
-				{Title: "Test Headline 2", URL: "http://example.com/2"},
-			},
-		},
-	}
-
-	// Test caching
-	CacheHeadlines(testHeadlines)
-
-	// Test retrieval
-	cachedHeadlines, isCached := GetCachedHeadlines()
-	if !isCached {
-		t.Error("Expected headlines to be cached")
-	}
-
-	if !reflect.DeepEqual(cachedHeadlines, testHeadlines) {
-		t.Error("Cached headlines do not match original headlines")
-	}
-
-	// Test cache expiration
-	cacheDuration = 1 * time.Millisecond
-	time.Sleep(2 * time.Millisecond)
-
-	_, isCached = GetCachedHeadlines()
-	if isCached {
-		t.Error("Expected cache to be expired")
-	}
-}
-
-func TestGetHeadlines(t *testing.T) {
-	mockClient1 := &MockNewsClient{
-		headlines: []NewsItem{{Title: "Test 1", URL: "http://test1.com"}},
-	}
-	mockClient2 := &MockNewsClient{
-		headlines: []NewsItem{{Title: "Test 2", URL: "http://test2.com"}},
-	}
-
-	sources := []NewsClient{mockClient1, mockClient2}
-
-	results := GetHeadlines(sources)
-
-	if len(results) != 2 {
-		t.Errorf("Expected 2 results, got %d", len(results))
-	}
-
-	if results[0].Headlines[0].Title != "Test 1" || results[1].Headlines[0].Title != "Test 2" {
-		t.Error("Unexpected headlines in results")
-	}
-}
-
 func TestCompleteURL(t *testing.T) {
 	testCases := []struct {
 		baseURL     string
@@ -87,12 +28,29 @@ func TestCompleteURL(t *testing.T) {
 	}
 }
 
+func TestResponseCurrent(t *testing.T) {
+	testCases := []struct {
+		response Response
+		current  bool
+	}{
+		{Response{}, true},
+		{Response{Error: &SourceError{Type: ErrorTypeFetchFailed}}, false},
+		{Response{Error: &SourceError{Type: ErrorTypeFetchFailed}, Stale: true}, false},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.response.Current(); got != tc.current {
+			t.Errorf("Current() of %+v = %v; want %v", tc.response, got, tc.current)
+		}
+	}
+}
+
 // MockNewsClient is a mock implementation of the NewsClient interface for testing
 type MockNewsClient struct {
 	headlines []NewsItem
 }
 
-func (m *MockNewsClient) GetHeadlines() (Response, error) {
+func (m *MockNewsClient) GetHeadlines(ctx context.Context) (Response, error) {
 	return Response{
 		Source:    SourceInfo{Name: "Mock Source", Logo: "http://mock.com/logo.png", Homepage: "http://mock.com"},
 		Headlines: m.headlines,
@@ -106,3 +64,28 @@ func (m *MockNewsClient) Name() string {
 func (m *MockNewsClient) SourceInfo() SourceInfo {
 	return SourceInfo{Name: "Mock Source", Logo: "http://mock.com/logo.png", Homepage: "http://mock.com"}
 }
+
+func TestCachingHTTPClientPageHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("<html>page</html>"))
+	}))
+	defer server.Close()
+
+	var pages []string
+	client := NewCachingHTTPClient(time.Second, "test-agent").WithPageHook(func(url string, body []byte) {
+		pages = append(pages, url+" "+string(body))
+	})
+
+	client.Get(server.URL)
+	// Cached pages and failed requests are not passed to the hook
+	client.Get(server.URL)
+	client.Get(server.URL + "/missing")
+
+	if len(pages) != 1 || pages[0] != server.URL+" <html>page</html>" {
+		t.Errorf("Expected the page to be passed to the hook once, got %q", pages)
+	}
+}
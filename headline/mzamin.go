@@ -1,21 +1,31 @@
 package headline
 
 import (
+	"context"
 	"fmt"
-	"io"
 	"strings"
 
 	"golang.org/x/net/html"
 )
 
+func init() {
+	Register("mzamin", func(opts Options) (NewsClient, error) {
+		client := NewMZaminClient(orDefault(opts.URL, "https://mzamin.com/"), opts.HTTPClient)
+		client.ID = opts.ID
+		return client, nil
+	})
+}
+
 // MZaminClient is a client to fetch headlines from mzamin.com
 type MZaminClient struct {
+	// ID is the configured ID of the source, mzamin when empty
+	ID         string
 	URL        string
-	HTTPClient *CachingHTTPClient
+	HTTPClient Fetcher
 }
 
 // NewMZaminClient creates a new MZaminClient
-func NewMZaminClient(url string, client *CachingHTTPClient) *MZaminClient {
+func NewMZaminClient(url string, client Fetcher) *MZaminClient {
 	return &MZaminClient{
 		URL:        url,
 		HTTPClient: client,
@@ -25,6 +35,7 @@ func NewMZaminClient(url string, client *CachingHTTPClient) *MZaminClient {
 // SourceInfo returns information about the news source
 func (c *MZaminClient) SourceInfo() SourceInfo {
 	return SourceInfo{
+		ID:       orDefault(c.ID, "mzamin"),
 		Name:     "মানবজমিন",
 		Logo:     "https://mzamin.com/assets/images/logo.png",
 		Homepage: "https://mzamin.com/",
@@ -32,21 +43,13 @@ func (c *MZaminClient) SourceInfo() SourceInfo {
 }
 
 // GetHeadlines fetches the headlines from mzamin.com
-func (c *MZaminClient) GetHeadlines() (Response, error) {
-	resp, err := c.HTTPClient.Get(c.URL)
-	if err != nil {
-		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to fetch the website: %v", err)
-	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(resp.Body)
-
-	body, err := io.ReadAll(resp.Body)
+func (c *MZaminClient) GetHeadlines(ctx context.Context) (Response, error) {
+	page, err := fetchPage(ctx, c.HTTPClient, c.URL)
 	if err != nil {
-		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to read the response body: %v", err)
+		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to fetch the website: %w", err)
 	}
 
-	items, err := c.extractMZaminNewsItems(string(body))
+	items, err := c.ExtractHeadlines(string(page.Body), nil)
 	if err != nil {
 		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to extract news items: %v", err)
 	}
@@ -57,7 +60,8 @@ func (c *MZaminClient) GetHeadlines() (Response, error) {
 	}, nil
 }
 
-func (c *MZaminClient) extractMZaminNewsItems(htmlContent string) ([]NewsItem, error) {
+// ExtractHeadlines extracts the headlines from the markup of the front page
+func (c *MZaminClient) ExtractHeadlines(htmlContent string, trace Tracer) ([]NewsItem, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %v", err)
@@ -67,19 +71,18 @@ func (c *MZaminClient) extractMZaminNewsItems(htmlContent string) ([]NewsItem, e
 	var f func(*html.Node)
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "h1" {
-			for _, a := range n.Attr {
-				if a.Key == "class" && a.Val == "display-3" {
-					title, url := c.extractMZaminTitleAndURL(n, c.URL)
-					if title != "" && url != "" {
-						newsItems = append(newsItems, NewsItem{Title: title, URL: url})
-					}
-					break
+			if getAttr(n, "class") == "display-3" {
+				title, url := c.extractMZaminTitleAndURL(n, c.URL)
+				if trace.candidate(n, "h1.display-3", title, url) {
+					newsItems = append(newsItems, NewsItem{Title: title, URL: url, Prominence: ProminenceLead})
 				}
+			} else {
+				trace.rejectNode(n, "h1.display-3", "class is not display-3")
 			}
 		} else if n.Type == html.ElementNode && n.Data == "h3" {
 			title, url := c.extractMZaminTitleAndURL(n, c.URL)
-			if title != "" && url != "" {
-				newsItems = append(newsItems, NewsItem{Title: title, URL: url})
+			if trace.candidate(n, "h3", title, url) {
+				newsItems = append(newsItems, NewsItem{Title: title, URL: url, Prominence: ProminenceRegular})
 			}
 		}
 		for child := n.FirstChild; child != nil; child = child.NextSibling {
@@ -88,7 +91,7 @@ func (c *MZaminClient) extractMZaminNewsItems(htmlContent string) ([]NewsItem, e
 	}
 	f(doc)
 
-	return newsItems, nil
+	return rankItems(newsItems), nil
 }
 
 func (c *MZaminClient) extractMZaminTitleAndURL(n *html.Node, baseURL string) (string, string) {
@@ -113,7 +116,7 @@ func (c *MZaminClient) extractMZaminTitleAndURL(n *html.Node, baseURL string) (s
 
 	url = completeURL(baseURL, url)
 
-	return strings.TrimSpace(title), url
+	return normalizeText(title), url
 }
 
 func extractText(n *html.Node) string {
@@ -125,5 +128,5 @@ func extractText(n *html.Node) string {
 			text += extractText(c)
 		}
 	}
-	return strings.TrimSpace(text)
+	return normalizeText(text)
 }
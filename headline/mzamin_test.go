@@ -1,6 +1,7 @@
 package headline
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -32,7 +33,7 @@ func TestMZaminClient_GetHeadlines(t *testing.T) {
 	client := NewMZaminClient(server.URL, NewCachingHTTPClient(0, "test-agent"))
 
 	// Get headlines
-	response, err := client.GetHeadlines()
+	response, err := client.GetHeadlines(context.Background())
 	if err != nil {
 		t.Fatalf("Error getting headlines: %v", err)
 	}
@@ -43,12 +44,13 @@ func TestMZaminClient_GetHeadlines(t *testing.T) {
 	}
 
 	expectedHeadlines := []struct {
-		title string
-		url   string
+		title      string
+		url        string
+		prominence string
 	}{
-		{"Headline 1", server.URL + "/article1"},
-		{"Headline 2", server.URL + "/article2"},
-		{"Continued", server.URL + "/article3"}, // Updated to match actual behavior
+		{"Headline 1", server.URL + "/article1", ProminenceLead},
+		{"Headline 2", server.URL + "/article2", ProminenceRegular},
+		{"Continued", server.URL + "/article3", ProminenceRegular}, // Updated to match actual behavior
 	}
 
 	for i, expected := range expectedHeadlines {
@@ -64,10 +66,15 @@ func TestMZaminClient_GetHeadlines(t *testing.T) {
 		if response.Headlines[i].URL != expected.url {
 			t.Errorf("Expected headline URL '%s', got '%s'", expected.url, response.Headlines[i].URL)
 		}
+
+		if response.Headlines[i].Rank != i+1 || response.Headlines[i].Prominence != expected.prominence {
+			t.Errorf("Expected rank %d and prominence %s, got %d and %s", i+1, expected.prominence, response.Headlines[i].Rank, response.Headlines[i].Prominence)
+		}
 	}
 
 	// Check source info
 	expectedSourceInfo := SourceInfo{
+		ID:       "mzamin",
 		Name:     "মানবজমিন",
 		Logo:     "https://mzamin.com/assets/images/logo.png",
 		Homepage: "https://mzamin.com/",
@@ -83,6 +90,7 @@ func TestMZaminClient_SourceInfo(t *testing.T) {
 	info := client.SourceInfo()
 
 	expectedInfo := SourceInfo{
+		ID:       "mzamin",
 		Name:     "মানবজমিন",
 		Logo:     "https://mzamin.com/assets/images/logo.png",
 		Homepage: "https://mzamin.com/",
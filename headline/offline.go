@@ -0,0 +1,186 @@
+package headline
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// NotRecordedError is returned by the offline transports for a page that was not recorded
+type NotRecordedError struct {
+	URL string
+}
+
+func (e *NotRecordedError) Error() string {
+	return fmt.Sprintf("%s was not recorded", e.URL)
+}
+
+// notRecorded answers a request the offline transports have no page for. A missing robots.txt
+// is answered with a 404, which allows everything as it would online.
+func notRecorded(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/robots.txt" {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Status:     "404 Not Found",
+			Header:     make(http.Header),
+			Body:       http.NoBody,
+			Request:    req,
+		}, nil
+	}
+	return nil, &NotRecordedError{URL: req.URL.String()}
+}
+
+// DirTransport serves requests from pages saved in a directory, without using the network.
+// The page of https://example.com/news/today is looked up as example.com/news/today,
+// example.com/news/today.html and example.com/news/today/index.html in that order. The query
+// string is ignored.
+type DirTransport struct {
+	Dir string
+}
+
+// NewDirTransport creates a transport serving the pages saved in dir
+func NewDirTransport(dir string) *DirTransport {
+	return &DirTransport{Dir: dir}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *DirTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// Cleaning the rooted path keeps the lookup inside the host's directory
+	name := path.Clean("/" + req.URL.Path)
+	base := filepath.Join(t.Dir, req.URL.Hostname(), filepath.FromSlash(name))
+	candidates := []string{filepath.Join(base, "index.html")}
+	if name != "/" {
+		candidates = []string{base, base + ".html", filepath.Join(base, "index.html")}
+	}
+
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		body, err := os.ReadFile(candidate)
+		if err != nil {
+			return nil, err
+		}
+		contentType := mime.TypeByExtension(filepath.Ext(candidate))
+		if contentType == "" {
+			contentType = "text/html"
+		}
+		return recordedResponse(req, http.StatusOK, http.Header{"Content-Type": {contentType}}, body), nil
+	}
+	return notRecorded(req)
+}
+
+// HARTransport replays the responses recorded in an HTTP Archive, e.g. one exported from the
+// network panel of a browser, without using the network. When a URL was recorded more than
+// once the last response is replayed.
+type HARTransport struct {
+	entries map[string]harResponse
+}
+
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method string `json:"method"`
+				URL    string `json:"url"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				Content struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+type harResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// LoadHARTransport creates a transport replaying the HTTP Archive at path
+func LoadHARTransport(path string) (*HARTransport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HAR: %v", err)
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR %s: %v", path, err)
+	}
+
+	t := &HARTransport{entries: make(map[string]harResponse, len(har.Log.Entries))}
+	for i, entry := range har.Log.Entries {
+		body := []byte(entry.Response.Content.Text)
+		if entry.Response.Content.Encoding == "base64" {
+			if body, err = base64.StdEncoding.DecodeString(entry.Response.Content.Text); err != nil {
+				return nil, fmt.Errorf("failed to decode HAR %s entry %d: %v", path, i, err)
+			}
+		}
+
+		header := make(http.Header)
+		for _, h := range entry.Response.Headers {
+			// The body is stored decoded and its length may differ from the recorded one
+			switch http.CanonicalHeaderKey(h.Name) {
+			case "Content-Encoding", "Content-Length", "Transfer-Encoding":
+				continue
+			}
+			header.Add(h.Name, h.Value)
+		}
+		if header.Get("Content-Type") == "" && entry.Response.Content.MimeType != "" {
+			header.Set("Content-Type", entry.Response.Content.MimeType)
+		}
+
+		method := entry.Request.Method
+		if method == "" {
+			method = http.MethodGet
+		}
+		t.entries[harKey(method, entry.Request.URL)] = harResponse{status: entry.Response.Status, header: header, body: body}
+	}
+	return t, nil
+}
+
+func harKey(method, rawURL string) string {
+	rawURL, _, _ = strings.Cut(rawURL, "#")
+	return strings.ToUpper(method) + " " + rawURL
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *HARTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, ok := t.entries[harKey(req.Method, req.URL.String())]
+	if !ok {
+		return notRecorded(req)
+	}
+	return recordedResponse(req, recorded.status, recorded.header.Clone(), recorded.body), nil
+}
+
+func recordedResponse(req *http.Request, status int, header http.Header, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,68 @@
+package headline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirTransport(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "mzamin.com", "sports"), 0o755)
+	os.WriteFile(filepath.Join(dir, "mzamin.com", "index.html"), []byte(`<html><body><h3><a href="/article1">Headline 1</a></h3></body></html>`), 0o644)
+	os.WriteFile(filepath.Join(dir, "mzamin.com", "sports.html"), []byte("sports"), 0o644)
+
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithTransport(NewDirTransport(dir)), WithRobots(time.Hour))
+	response, err := NewMZaminClient("https://mzamin.com/", client).GetHeadlines(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting headlines: %v", err)
+	}
+	if len(response.Headlines) != 1 || response.Headlines[0].URL != "https://mzamin.com/article1" {
+		t.Errorf("Expected the headline of the saved page, got %+v", response.Headlines)
+	}
+
+	resp, err := client.Get("https://mzamin.com/sports?page=1")
+	if err != nil {
+		t.Fatalf("Error getting section: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "sports" {
+		t.Errorf("Expected sports.html, got %q", body)
+	}
+
+	var notRecorded *NotRecordedError
+	if _, err := client.Get("https://mzamin.com/../../etc/passwd"); !errors.As(err, &notRecorded) {
+		t.Errorf("Expected a NotRecordedError, got %v", err)
+	}
+}
+
+func TestHARTransport(t *testing.T) {
+	har := filepath.Join(t.TempDir(), "recording.har")
+	os.WriteFile(har, []byte(`{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://example.com/"},
+		 "response": {"status": 200, "headers": [{"name": "Content-Type", "value": "text/html"}, {"name": "Content-Length", "value": "1"}],
+		              "content": {"mimeType": "text/html", "text": "old"}}},
+		{"request": {"method": "GET", "url": "https://example.com/"},
+		 "response": {"status": 200, "headers": [], "content": {"mimeType": "text/html", "text": "PGh0bWw+bmV3PC9odG1sPg==", "encoding": "base64"}}}
+	]}}`), 0o644)
+
+	transport, err := LoadHARTransport(har)
+	if err != nil {
+		t.Fatalf("Error loading HAR: %v", err)
+	}
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithTransport(transport))
+
+	resp, err := client.Get("https://example.com/")
+	if err != nil {
+		t.Fatalf("Error getting page: %v", err)
+	}
+	if body, _ := io.ReadAll(resp.Body); string(body) != "<html>new</html>" {
+		t.Errorf("Expected the last recorded response, got %q", body)
+	}
+	if _, err := client.Get("https://example.com/other"); err == nil {
+		t.Error("Expected an error for a page that was not recorded")
+	}
+}
@@ -1,21 +1,51 @@
 package headline
 
 import (
+	"context"
 	"fmt"
-	"io"
+	"log"
 	"strings"
 
 	"golang.org/x/net/html"
 )
 
+func init() {
+	Register("prothomalo", func(opts Options) (NewsClient, error) {
+		client := NewProthomAloClient(orDefault(opts.URL, "https://www.prothomalo.com/"), opts.HTTPClient)
+		client.ID = opts.ID
+		switch opts.Params["mode"] {
+		case "", "html":
+		case "embedded":
+			embedded := EmbeddedJSONFromParams(opts.Params, ProthomAloEmbeddedJSON)
+			client.Embedded = &embedded
+		default:
+			return nil, fmt.Errorf("unknown mode %q, use html or embedded", opts.Params["mode"])
+		}
+		return client, nil
+	})
+}
+
+// ProthomAloEmbeddedJSON locates the stories in the Quintype page state embedded in prothomalo.com
+var ProthomAloEmbeddedJSON = EmbeddedJSON{
+	Script: "#static-page",
+	Items:  "qt.data.collection.items[*].story",
+	Title:  "headline",
+	URL:    "url|slug",
+}
+
 // ProthomAloClient is a client to fetch headlines from prothomalo.com
 type ProthomAloClient struct {
+	// ID is the configured ID of the source, prothomalo when empty
+	ID         string
 	URL        string
-	HTTPClient *CachingHTTPClient
+	HTTPClient Fetcher
+	// Embedded extracts the headlines from the embedded page state instead of the markup when set.
+	// The markup is used as a fallback if the page state yields no headlines.
+	Embedded *EmbeddedJSON
 }
 
 // NewProthomAloClient creates a new ProthomAloClient
-func NewProthomAloClient(url string, client *CachingHTTPClient) *ProthomAloClient {
+func NewProthomAloClient(url string, client Fetcher) *ProthomAloClient {
 	return &ProthomAloClient{
 		URL:        url,
 		HTTPClient: client,
@@ -25,6 +55,7 @@ func NewProthomAloClient(url string, client *CachingHTTPClient) *ProthomAloClien
 // SourceInfo returns information about the news source
 func (c *ProthomAloClient) SourceInfo() SourceInfo {
 	return SourceInfo{
+		ID:       orDefault(c.ID, "prothomalo"),
 		Name:     "ProthomAlo",
 		Logo:     "https://encrypted-tbn0.gstatic.com/images?q=tbn:ANd9GcSUTX3amtUek4Ia80_rbqUkfwS6sYaeSUdqwg&s",
 		Homepage: "https://www.prothomalo.com",
@@ -32,29 +63,39 @@ func (c *ProthomAloClient) SourceInfo() SourceInfo {
 }
 
 // GetHeadlines fetches the headlines from prothomalo.com
-func (c *ProthomAloClient) GetHeadlines() (Response, error) {
-	resp, err := c.HTTPClient.Get(c.URL)
-	if err != nil {
-		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to fetch the website: %v", err)
-	}
-	defer func(Body io.ReadCloser) {
-		_ = Body.Close()
-	}(resp.Body)
-
-	body, err := io.ReadAll(resp.Body)
+func (c *ProthomAloClient) GetHeadlines(ctx context.Context) (Response, error) {
+	page, err := fetchPage(ctx, c.HTTPClient, c.URL)
 	if err != nil {
-		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to read the response body: %v", err)
+		return Response{Source: c.SourceInfo()}, fmt.Errorf("failed to fetch the website: %w", err)
 	}
 
-	items, err := c.extractNewsItems(string(body))
-
+	items, _ := c.ExtractHeadlines(string(page.Body), nil)
 	return Response{
 		Source:    c.SourceInfo(),
 		Headlines: items,
 	}, nil
 }
 
-func (c *ProthomAloClient) extractNewsItems(htmlContent string) ([]NewsItem, error) {
+// ExtractHeadlines extracts the headlines from the embedded page state if enabled, falling back
+// to the markup of the front page
+func (c *ProthomAloClient) ExtractHeadlines(htmlContent string, trace Tracer) ([]NewsItem, error) {
+	var items []NewsItem
+	var err error
+	if c.Embedded != nil {
+		items, err = c.Embedded.ExtractTraced(htmlContent, c.URL, trace)
+		if err != nil {
+			log.Printf("Falling back to HTML extraction for %s: %v", c.SourceInfo().Name, err)
+		}
+	}
+	if len(items) == 0 {
+		items, err = c.extractNewsItems(htmlContent, trace)
+	}
+
+	// The page does not mark the lead and top stories, they are ranked by position
+	return rankItems(items), err
+}
+
+func (c *ProthomAloClient) extractNewsItems(htmlContent string, trace Tracer) ([]NewsItem, error) {
 	doc, err := html.Parse(strings.NewReader(htmlContent))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse HTML: %v", err)
@@ -64,14 +105,13 @@ func (c *ProthomAloClient) extractNewsItems(htmlContent string) ([]NewsItem, err
 	var f func(*html.Node)
 	f = func(n *html.Node) {
 		if n.Type == html.ElementNode && n.Data == "h3" {
-			for _, a := range n.Attr {
-				if a.Key == "class" && strings.Contains(a.Val, "headline-title") {
-					title, url := c.extractTitleAndURL(n)
-					if title != "" && url != "" {
-						newsItems = append(newsItems, NewsItem{Title: title, URL: url})
-					}
-					break
+			if strings.Contains(getAttr(n, "class"), "headline-title") {
+				title, url := c.extractTitleAndURL(n)
+				if trace.candidate(n, "h3.headline-title", title, url) {
+					newsItems = append(newsItems, NewsItem{Title: title, URL: url})
 				}
+			} else {
+				trace.rejectNode(n, "h3.headline-title", "class does not contain headline-title")
 			}
 		}
 		for c := n.FirstChild; c != nil; c = c.NextSibling {
@@ -1,6 +1,7 @@
 package headline
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -33,7 +34,7 @@ func TestProthomAloClient_GetHeadlines(t *testing.T) {
 	client := NewProthomAloClient(server.URL, NewCachingHTTPClient(0, "test-agent"))
 
 	// Get headlines
-	response, err := client.GetHeadlines()
+	response, err := client.GetHeadlines(context.Background())
 	if err != nil {
 		t.Fatalf("Error getting headlines: %v", err)
 	}
@@ -0,0 +1,52 @@
+package headline
+
+// Prominence tiers of a headline on its front page
+const (
+	// ProminenceLead is the lead story of the page
+	ProminenceLead = "lead"
+	// ProminenceTop are the stories of the top section of the page
+	ProminenceTop = "top"
+	// ProminenceRegular are all other stories
+	ProminenceRegular = "regular"
+	// ProminenceSection are the stories only found on a section page, which are not on the front
+	// page and have no rank
+	ProminenceSection = "section"
+)
+
+// topPositions is the number of stories after the lead that are top stories when a page's markup
+// does not tell them apart
+const topPositions = 4
+
+// rankItems sets the rank of the items to their position on the page. Items without a prominence
+// get one by position: the first item is the lead, the next topPositions are top stories.
+func rankItems(items []NewsItem) []NewsItem {
+	for i := range items {
+		items[i].Rank = i + 1
+		if items[i].Prominence != "" {
+			continue
+		}
+		switch {
+		case i == 0:
+			items[i].Prominence = ProminenceLead
+		case i <= topPositions:
+			items[i].Prominence = ProminenceTop
+		default:
+			items[i].Prominence = ProminenceRegular
+		}
+	}
+	return items
+}
+
+// ProminenceWeight returns the weight of a prominence tier for ranking stories across sources
+func ProminenceWeight(prominence string) float64 {
+	switch prominence {
+	case ProminenceLead:
+		return 3
+	case ProminenceTop:
+		return 2
+	case ProminenceSection:
+		return 0
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,176 @@
+package headline
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit configures the politeness controls the CachingHTTPClient applies to every host
+type RateLimit struct {
+	// RequestsPerSecond is the rate at which request tokens are refilled. Zero disables the token bucket.
+	RequestsPerSecond float64
+	// Burst is the maximum number of tokens in the bucket
+	Burst int
+	// MaxConcurrent is the maximum number of concurrent requests. Zero means unlimited.
+	MaxConcurrent int
+	// MinDelay is the minimum delay between the start of two requests
+	MinDelay time.Duration
+	// MaxRetryAfter is the longest Retry-After delay the client waits for before retrying once.
+	// Longer delays fail the request immediately; the host stays blocked until the delay has passed.
+	MaxRetryAfter time.Duration
+}
+
+// WithRateLimit enables per-host rate limiting
+func WithRateLimit(limit RateLimit) ClientOption {
+	return func(c *CachingHTTPClient) {
+		c.rateLimit = &limit
+	}
+}
+
+// hostLimiter enforces the RateLimit for a single host
+type hostLimiter struct {
+	limit RateLimit
+	sem   chan struct{}
+
+	mu           sync.Mutex
+	tokens       float64
+	refilledAt   time.Time
+	lastRequest  time.Time
+	blockedUntil time.Time
+	minDelay     time.Duration
+}
+
+func newHostLimiter(limit RateLimit, now time.Time) *hostLimiter {
+	h := &hostLimiter{
+		limit:      limit,
+		tokens:     float64(max(limit.Burst, 1)),
+		refilledAt: now,
+		minDelay:   limit.MinDelay,
+	}
+	if limit.MaxConcurrent > 0 {
+		h.sem = make(chan struct{}, limit.MaxConcurrent)
+	}
+	return h
+}
+
+// acquire blocks until a request to the host is allowed or ctx is done, in which case it
+// returns the error of ctx. Otherwise the returned function must be called once the request
+// has completed.
+func (h *hostLimiter) acquire(ctx context.Context, now func() time.Time, sleep func(context.Context, time.Duration) error) (func(), error) {
+	release := func() {
+		if h.sem != nil {
+			<-h.sem
+		}
+	}
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	for {
+		wait := h.reserve(now())
+		if wait <= 0 {
+			break
+		}
+		if err := sleep(ctx, wait); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// sleepContext waits for d, returning early with the error of ctx when it is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// reserve takes a token if a request is allowed at t, otherwise it returns how long to wait
+func (h *hostLimiter) reserve(t time.Time) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var wait time.Duration
+	if t.Before(h.blockedUntil) {
+		wait = h.blockedUntil.Sub(t)
+	}
+	if next := h.lastRequest.Add(h.minDelay); t.Before(next) {
+		wait = max(wait, next.Sub(t))
+	}
+
+	if h.limit.RequestsPerSecond > 0 {
+		burst := float64(max(h.limit.Burst, 1))
+		h.tokens = min(burst, h.tokens+t.Sub(h.refilledAt).Seconds()*h.limit.RequestsPerSecond)
+		h.refilledAt = t
+		if h.tokens < 1 {
+			wait = max(wait, time.Duration((1-h.tokens)/h.limit.RequestsPerSecond*float64(time.Second)))
+		}
+	}
+
+	if wait > 0 {
+		return wait
+	}
+
+	if h.limit.RequestsPerSecond > 0 {
+		h.tokens--
+	}
+	h.lastRequest = t
+	return 0
+}
+
+// block prevents requests to the host until t
+func (h *hostLimiter) block(t time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if t.After(h.blockedUntil) {
+		h.blockedUntil = t
+	}
+}
+
+// setMinDelay raises the minimum delay between requests, e.g. to honour a robots.txt Crawl-delay
+func (h *hostLimiter) setMinDelay(d time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.minDelay = max(h.limit.MinDelay, d)
+}
+
+// limiter returns the limiter for the given host, creating it if needed
+func (c *CachingHTTPClient) limiter(host string) *hostLimiter {
+	if c.rateLimit == nil {
+		return nil
+	}
+	if h, ok := c.limiters.Load(host); ok {
+		return h.(*hostLimiter)
+	}
+	h, _ := c.limiters.LoadOrStore(host, newHostLimiter(*c.rateLimit, c.now()))
+	return h.(*hostLimiter)
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
@@ -0,0 +1,150 @@
+package headline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHostLimiterTokenBucket(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newHostLimiter(RateLimit{RequestsPerSecond: 2, Burst: 2}, start)
+
+	// The burst is available immediately
+	for i := 0; i < 2; i++ {
+		if wait := h.reserve(start); wait != 0 {
+			t.Fatalf("Expected request %d to be allowed, got wait %s", i, wait)
+		}
+	}
+
+	// The next token is refilled after half a second
+	if wait := h.reserve(start); wait != 500*time.Millisecond {
+		t.Errorf("Expected a wait of 500ms, got %s", wait)
+	}
+	if wait := h.reserve(start.Add(500 * time.Millisecond)); wait != 0 {
+		t.Errorf("Expected request to be allowed after the refill, got wait %s", wait)
+	}
+}
+
+func TestHostLimiterMinDelayAndBlock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := newHostLimiter(RateLimit{MinDelay: time.Second}, start)
+
+	if wait := h.reserve(start); wait != 0 {
+		t.Fatalf("Expected first request to be allowed, got wait %s", wait)
+	}
+	if wait := h.reserve(start.Add(200 * time.Millisecond)); wait != 800*time.Millisecond {
+		t.Errorf("Expected a wait of 800ms, got %s", wait)
+	}
+
+	h.block(start.Add(5 * time.Second))
+	if wait := h.reserve(start.Add(2 * time.Second)); wait != 3*time.Second {
+		t.Errorf("Expected a wait of 3s while blocked, got %s", wait)
+	}
+}
+
+func TestHostLimiterAcquireCancelled(t *testing.T) {
+	h := newHostLimiter(RateLimit{MaxConcurrent: 1, MinDelay: time.Hour}, time.Now())
+	release, err := h.acquire(context.Background(), time.Now, sleepContext)
+	if err != nil {
+		t.Fatalf("Expected the first request to be allowed, got %v", err)
+	}
+
+	// Waiting for the delay between requests ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	release()
+	if _, err := h.acquire(ctx, time.Now, sleepContext); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the wait for the delay to be cancelled, got %v", err)
+	}
+
+	// So does waiting for a concurrent request, and the slot of the cancelled one is released
+	h = newHostLimiter(RateLimit{MaxConcurrent: 1}, time.Now())
+	release, _ = h.acquire(context.Background(), time.Now, sleepContext)
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := h.acquire(ctx, time.Now, sleepContext); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the wait for a concurrent request to be cancelled, got %v", err)
+	}
+	release()
+	if release, err = h.acquire(context.Background(), time.Now, sleepContext); err != nil {
+		t.Errorf("Expected the slot to be free, got %v", err)
+	}
+	release()
+}
+
+func TestCachingHTTPClientRetryAfter(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var slept time.Duration
+	now := time.Now()
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithRateLimit(RateLimit{MaxRetryAfter: 5 * time.Second}))
+	client.now = func() time.Time { return now.Add(slept) }
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the request to be retried, got %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "ok" {
+		t.Errorf("Expected body 'ok', got '%s'", body)
+	}
+	if requests != 2 {
+		t.Errorf("Expected 2 requests, got %d", requests)
+	}
+	if slept != 2*time.Second {
+		t.Errorf("Expected to wait 2s for Retry-After, waited %s", slept)
+	}
+}
+
+func TestCachingHTTPClientRetryAfterTooLong(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "120")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithRateLimit(RateLimit{MaxRetryAfter: 5 * time.Second}))
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("Expected an error when Retry-After exceeds the maximum")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"30", 30 * time.Second, true},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+
+	for _, tc := range testCases {
+		d, ok := parseRetryAfter(tc.value, now)
+		if d != tc.expected || ok != tc.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tc.value, d, ok, tc.expected, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package headline
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Options are the options passed to a Factory when creating a news source
+type Options struct {
+	// ID is the configured ID of the source. It differs from the registered ID when a generic
+	// source such as embedded-json is configured more than once.
+	ID string
+	// URL is the page to scrape. Factories fall back to the source's homepage when empty.
+	URL string
+	// HTTPClient fetches the pages, usually the shared CachingHTTPClient
+	HTTPClient Fetcher
+	// Params are source specific settings
+	Params map[string]string
+}
+
+// Factory creates a NewsClient from the given options
+type Factory func(opts Options) (NewsClient, error)
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+)
+
+// Register makes a news source available under the given ID.
+// It is intended to be called from the init function of the package implementing the source,
+// so that other packages can add sources with a blank import.
+// Register panics if the ID is empty, the factory is nil or the ID is already registered.
+func Register(id string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if id == "" {
+		panic("headline: Register called with an empty source ID")
+	}
+	if factory == nil {
+		panic("headline: Register factory is nil for source " + id)
+	}
+	if _, dup := factories[id]; dup {
+		panic("headline: Register called twice for source " + id)
+	}
+	factories[id] = factory
+}
+
+// New creates the news source registered under the given ID
+func New(id string, opts Options) (NewsClient, error) {
+	registryMu.RLock()
+	factory, ok := factories[id]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q", id)
+	}
+
+	client, err := factory(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create source %q: %v", id, err)
+	}
+	return client, nil
+}
+
+// Available returns the sorted IDs of all registered sources
+func Available() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	ids := make([]string, 0, len(factories))
+	for id := range factories {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// orDefault returns value, or fallback when value is empty
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
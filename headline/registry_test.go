@@ -0,0 +1,71 @@
+package headline
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	Register("test-registry", func(opts Options) (NewsClient, error) {
+		return &MockNewsClient{headlines: []NewsItem{{Title: opts.Params["title"], URL: opts.URL}}}, nil
+	})
+
+	client, err := New("test-registry", Options{URL: "http://example.com", Params: map[string]string{"title": "Test"}})
+	if err != nil {
+		t.Fatalf("Error creating source: %v", err)
+	}
+
+	response, err := client.GetHeadlines(context.Background())
+	if err != nil {
+		t.Fatalf("Error getting headlines: %v", err)
+	}
+	expected := []NewsItem{{Title: "Test", URL: "http://example.com"}}
+	if !reflect.DeepEqual(response.Headlines, expected) {
+		t.Errorf("Expected headlines %v, got %v", expected, response.Headlines)
+	}
+
+	if _, err := New("does-not-exist", Options{}); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+}
+
+func TestRegistryBuiltinSources(t *testing.T) {
+	available := make(map[string]bool)
+	for _, id := range Available() {
+		available[id] = true
+	}
+
+	for _, id := range []string{"prothomalo", "mzamin", "dailystarbangla"} {
+		if !available[id] {
+			t.Errorf("Expected source %s to be registered", id)
+			continue
+		}
+
+		client, err := New(id, Options{})
+		if err != nil {
+			t.Fatalf("Error creating source %s: %v", id, err)
+		}
+		if client.SourceInfo().ID != id {
+			t.Errorf("Expected source ID %s, got %s", id, client.SourceInfo().ID)
+		}
+
+		// A source configured under another ID reports it
+		client, err = New(id, Options{ID: id + "-sports"})
+		if err != nil {
+			t.Fatalf("Error creating source %s: %v", id, err)
+		}
+		if client.SourceInfo().ID != id+"-sports" {
+			t.Errorf("Expected the configured ID %s-sports, got %s", id, client.SourceInfo().ID)
+		}
+	}
+}
+
+func TestRegisterDuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Register to panic on a duplicate ID")
+		}
+	}()
+	Register("prothomalo", func(opts Options) (NewsClient, error) { return nil, nil })
+}
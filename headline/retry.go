@@ -0,0 +1,74 @@
+package headline
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures how the CachingHTTPClient retries failed requests.
+// Only GET requests are made, so every request is safe to retry.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. It doubles with every retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts
+	MaxBackoff time.Duration
+	// Jitter randomizes each delay by up to this fraction in either direction, between 0 and 1
+	Jitter float64
+}
+
+// WithRetry enables retrying network errors and 408, 429 and 5xx responses
+func WithRetry(policy RetryPolicy) ClientOption {
+	return func(c *CachingHTTPClient) {
+		c.retry = &policy
+	}
+}
+
+// StatusError is returned when a server keeps responding with a status that indicates
+// a temporary failure
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the delay requested by the server, if any
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s responded with status %d, retry after %s", e.URL, e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("%s responded with status %d", e.URL, e.StatusCode)
+}
+
+// temporaryStatus reports whether the status code indicates a failure worth retrying
+func temporaryStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// retryable reports whether a request that ended with resp and err should be retried
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		var robotsErr *RobotsDisallowedError
+		var notRecorded *NotRecordedError
+		return !errors.As(err, &robotsErr) && !errors.As(err, &notRecorded)
+	}
+	return temporaryStatus(resp.StatusCode)
+}
+
+// backoff returns the delay before the given retry, starting at 1
+func (p *RetryPolicy) backoff(retry int, random func() float64) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d += time.Duration((random()*2 - 1) * p.Jitter * float64(d))
+	}
+	return max(d, 0)
+}
@@ -0,0 +1,90 @@
+package headline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCachingHTTPClientRetry(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var delays []time.Duration
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithRetry(RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	}))
+	client.sleep = func(_ context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	}
+
+	if _, err := client.Get(server.URL); err != nil {
+		t.Fatalf("Expected the request to succeed after retries, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected 3 requests, got %d", requests)
+	}
+	if len(delays) != 2 || delays[0] != 100*time.Millisecond || delays[1] != 200*time.Millisecond {
+		t.Errorf("Expected backoffs of 100ms and 200ms, got %v", delays)
+	}
+
+	// Once the attempts are exhausted the status is returned as an error
+	requests = -10
+	_, err := client.Get(server.URL + "/failing")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected a StatusError with status 502, got %v", err)
+	}
+}
+
+func TestCachingHTTPClientRetryCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.Fetch(ctx, server.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the backoff to end with the context, got %v", err)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Jitter: 0.5}
+
+	testCases := []struct {
+		retry    int
+		random   float64
+		expected time.Duration
+	}{
+		{1, 0.5, time.Second},
+		{2, 0.5, 2 * time.Second},
+		{3, 0.5, 4 * time.Second},
+		{4, 0.5, 5 * time.Second},
+		{1, 0, 500 * time.Millisecond},
+		{1, 1, 1500 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		got := policy.backoff(tc.retry, func() float64 { return tc.random })
+		if got != tc.expected {
+			t.Errorf("backoff(%d) with random %v = %s; want %s", tc.retry, tc.random, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package headline
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// RobotsDisallowedError is returned when robots.txt does not allow fetching a URL
+type RobotsDisallowedError struct {
+	URL string
+}
+
+func (e *RobotsDisallowedError) Error() string {
+	return fmt.Sprintf("fetching %s is disallowed by robots.txt", e.URL)
+}
+
+// WithRobots makes the client fetch and honour each host's robots.txt.
+// Rules are cached for ttl.
+func WithRobots(ttl time.Duration) ClientOption {
+	return func(c *CachingHTTPClient) {
+		c.robots = &robotsCache{ttl: ttl}
+	}
+}
+
+// WithoutRobots returns a client sharing c's caches and rate limits that does not consult robots.txt.
+// It is meant for sources with an explicit override.
+func (c *CachingHTTPClient) WithoutRobots() *CachingHTTPClient {
+	clone := *c
+	clone.ignoreRobots = true
+	return &clone
+}
+
+type robotsCache struct {
+	ttl   time.Duration
+	hosts sync.Map
+	// fetches lets the concurrent requests to a host share the fetch of its robots.txt
+	fetches singleflight.Group
+}
+
+type robotsEntry struct {
+	mu        sync.Mutex
+	rules     *robotsRules
+	fetchedAt time.Time
+}
+
+// robotsAllowed reports whether robots.txt allows fetching u. Waiting for robots.txt to be
+// fetched ends with the error of ctx when it is done.
+func (c *CachingHTTPClient) robotsAllowed(ctx context.Context, u *url.URL) (bool, error) {
+	if c.robots == nil || c.ignoreRobots {
+		return true, nil
+	}
+
+	host := u.Scheme + "://" + u.Host
+	e, _ := c.robots.hosts.LoadOrStore(host, &robotsEntry{})
+	entry := e.(*robotsEntry)
+
+	// The entry is not locked while robots.txt is fetched, so that a slow host only delays its own requests
+	entry.mu.Lock()
+	rules := entry.rules
+	if rules != nil && c.now().Sub(entry.fetchedAt) >= c.robots.ttl {
+		rules = nil
+	}
+	entry.mu.Unlock()
+
+	for attempt := 1; rules == nil; attempt++ {
+		fetched := c.robots.fetches.DoChan(host, func() (any, error) {
+			rules, err := c.fetchRobots(ctx, host, u.Host)
+			if err != nil {
+				return nil, err
+			}
+			entry.mu.Lock()
+			entry.rules = rules
+			entry.fetchedAt = c.now()
+			entry.mu.Unlock()
+
+			if limiter := c.limiter(u.Host); limiter != nil {
+				limiter.setMinDelay(rules.crawlDelay)
+			}
+			return rules, nil
+		})
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case result := <-fetched:
+			// A fetch shared with a request that was cancelled is made again for this one
+			if result.Shared && attempt == 1 && contextError(result.Err) {
+				continue
+			}
+			if result.Err != nil {
+				return false, fmt.Errorf("failed to fetch robots.txt: %w", result.Err)
+			}
+			rules = result.Val.(*robotsRules)
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	return rules.allowed(path), nil
+}
+
+func contextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// fetchRobots fetches and parses the robots.txt of a host, retrying it like any other request.
+// Following RFC 9309, a missing robots.txt allows everything and a server error disallows everything.
+func (c *CachingHTTPClient) fetchRobots(ctx context.Context, origin, host string) (*robotsRules, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", origin+"/robots.txt", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+
+	limiter := c.limiter(host)
+	var resp *http.Response
+	var body []byte
+	for attempt := 1; ; attempt++ {
+		resp, body, err = c.do(req, limiter)
+		if c.retry == nil || attempt >= c.retry.MaxAttempts || !retryable(resp, err) {
+			break
+		}
+		if err := c.sleep(ctx, c.retry.backoff(attempt, c.random)); err != nil {
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return parseRobots(strings.NewReader(string(body)), c.userAgent), nil
+	case resp.StatusCode >= 500:
+		return &robotsRules{rules: []robotsRule{newRobotsRule("/", false)}}, nil
+	default:
+		return &robotsRules{}, nil
+	}
+}
+
+type robotsRule struct {
+	pattern string
+	re      *regexp.Regexp
+	allow   bool
+}
+
+func newRobotsRule(pattern string, allow bool) robotsRule {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSuffix(pattern, "$")), `\*`, ".*")
+	if strings.HasSuffix(pattern, "$") {
+		expr += "$"
+	}
+	return robotsRule{pattern: pattern, re: regexp.MustCompile(expr), allow: allow}
+}
+
+// robotsRules are the rules of the robots.txt group that applies to our user agent
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// allowed reports whether path is allowed. The longest matching rule wins and Allow wins ties.
+func (r *robotsRules) allowed(path string) bool {
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !rule.re.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > best || (len(rule.pattern) == best && rule.allow) {
+			best = len(rule.pattern)
+			allow = rule.allow
+		}
+	}
+	return allow
+}
+
+// parseRobots parses a robots.txt and returns the rules for userAgent. The group naming the
+// product token of userAgent is used, falling back to the group for all user agents.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	token := strings.ToLower(userAgent)
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+
+	var (
+		specific, wildcard     robotsRules
+		hasSpecific            bool
+		groupAgents            []string
+		inRules                bool
+		matchesUs, matchesStar bool
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			// A user-agent line after rules starts a new group
+			if inRules {
+				groupAgents = nil
+				inRules = false
+			}
+			groupAgents = append(groupAgents, strings.ToLower(value))
+			matchesUs, matchesStar = false, false
+			for _, agent := range groupAgents {
+				if agent == "*" {
+					matchesStar = true
+				} else if agent == token {
+					matchesUs = true
+				}
+			}
+			continue
+		}
+
+		inRules = true
+		var target *robotsRules
+		switch {
+		case matchesUs:
+			target = &specific
+			hasSpecific = true
+		case matchesStar:
+			target = &wildcard
+		default:
+			continue
+		}
+
+		switch key {
+		case "allow", "disallow":
+			// An empty Disallow allows everything and adds no rule
+			if value != "" {
+				target.rules = append(target.rules, newRobotsRule(value, key == "allow"))
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+				target.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+	}
+
+	if hasSpecific {
+		return &specific
+	}
+	return &wildcard
+}
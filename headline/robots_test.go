@@ -0,0 +1,141 @@
+package headline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testRobots = `
+# Example robots.txt
+User-agent: *
+Disallow: /private/
+Allow: /private/public$
+Crawl-delay: 1
+
+User-agent: otherbot
+Disallow: /
+
+User-agent: headlines
+User-agent: somebot
+Disallow: /search
+Disallow: /*.pdf$
+Crawl-delay: 2.5
+`
+
+func TestParseRobots(t *testing.T) {
+	testCases := []struct {
+		userAgent string
+		path      string
+		allowed   bool
+	}{
+		{"headlines/1.0", "/", true},
+		{"headlines/1.0", "/private/page", true},
+		{"headlines/1.0", "/search?q=news", false},
+		{"headlines/1.0", "/files/report.pdf", false},
+		{"headlines/1.0", "/files/report.pdf?download=1", true},
+		{"test-agent", "/private/page", false},
+		{"test-agent", "/private/public", true},
+		{"test-agent", "/private/public/more", false},
+		{"otherbot/2.0", "/anything", false},
+	}
+
+	for _, tc := range testCases {
+		rules := parseRobots(strings.NewReader(testRobots), tc.userAgent)
+		if got := rules.allowed(tc.path); got != tc.allowed {
+			t.Errorf("allowed(%s) for %s = %v; want %v", tc.path, tc.userAgent, got, tc.allowed)
+		}
+	}
+
+	if rules := parseRobots(strings.NewReader(testRobots), "headlines/1.0"); rules.crawlDelay != 2500*time.Millisecond {
+		t.Errorf("Expected crawl delay of 2.5s, got %s", rules.crawlDelay)
+	}
+}
+
+func TestCachingHTTPClientRobots(t *testing.T) {
+	robotsRequests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			robotsRequests++
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Write([]byte(`<h3 class="headline-title"><a href="/news/1"><span>Test Headline</span></a></h3>`))
+	}))
+	defer server.Close()
+
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithRobots(time.Hour))
+
+	if _, err := client.Get(server.URL + "/news"); err != nil {
+		t.Errorf("Expected allowed URL to be fetched, got %v", err)
+	}
+
+	_, err := client.Get(server.URL + "/private/page")
+	var robotsErr *RobotsDisallowedError
+	if !errors.As(err, &robotsErr) {
+		t.Errorf("Expected a RobotsDisallowedError, got %v", err)
+	}
+
+	if robotsRequests != 1 {
+		t.Errorf("Expected robots.txt to be fetched once, got %d", robotsRequests)
+	}
+
+	if _, err := client.WithoutRobots().Get(server.URL + "/private/page"); err != nil {
+		t.Errorf("Expected the override to ignore robots.txt, got %v", err)
+	}
+
+	// The page cached by the override is not served to the client honouring robots.txt
+	if _, err := client.Get(server.URL + "/private/page"); !errors.As(err, &robotsErr) {
+		t.Errorf("Expected a RobotsDisallowedError for the cached page, got %v", err)
+	}
+
+	// The disallowed source is reported in its response
+	results := NewAggregator(func() []NewsClient { return []NewsClient{NewProthomAloClient(server.URL+"/private", client)} }, DefaultCachePolicy).GetHeadlines(context.Background())
+	if results[0].Error == nil || results[0].Error.Type != ErrorTypeRobotsDisallowed {
+		t.Errorf("Expected a %s error, got %+v", ErrorTypeRobotsDisallowed, results[0].Error)
+	}
+}
+
+func TestCachingHTTPClientRobotsCancelled(t *testing.T) {
+	var slow atomic.Bool
+	slow.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			if slow.Load() {
+				<-r.Context().Done()
+				return
+			}
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithRobots(time.Hour))
+
+	// A slow robots.txt ends with the context of the request
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Fetch(ctx, server.URL+"/news"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the fetch to end with its context, took %s", elapsed)
+	}
+
+	// The host is not left blocked
+	slow.Store(false)
+	if _, err := client.Fetch(context.Background(), server.URL+"/news"); err != nil {
+		t.Errorf("Expected the page once robots.txt is served, got %v", err)
+	}
+	if _, err := client.Fetch(context.Background(), server.URL+"/private"); err == nil {
+		t.Error("Expected robots.txt to be honoured once fetched")
+	}
+}
@@ -0,0 +1,94 @@
+package headline
+
+import (
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Outcomes of a TraceEvent
+const (
+	TraceMatched  = "matched"
+	TraceRejected = "rejected"
+)
+
+// TraceEvent explains what the extraction of headlines decided for a candidate element
+type TraceEvent struct {
+	// Path locates the candidate, e.g. "html > body > div.main > h3.title" for an element or the
+	// items path and index for embedded JSON
+	Path string `json:"path"`
+	// Rule is the selector or rule that made the element a candidate
+	Rule    string `json:"rule"`
+	Outcome string `json:"outcome"`
+	// Reason is why a candidate was rejected
+	Reason string `json:"reason,omitempty"`
+	Title  string `json:"title,omitempty"`
+	URL    string `json:"url,omitempty"`
+}
+
+// Tracer receives the trace events of an extraction. A nil Tracer discards them.
+type Tracer func(TraceEvent)
+
+// TracingExtractor is implemented by the clients whose extraction can be traced
+type TracingExtractor interface {
+	NewsClient
+	// ExtractHeadlines extracts the headlines from a page as GetHeadlines does after fetching it,
+	// reporting every candidate to trace
+	ExtractHeadlines(htmlContent string, trace Tracer) ([]NewsItem, error)
+}
+
+// candidate reports a candidate element, matched when it has a title and a URL and rejected otherwise
+func (t Tracer) candidate(n *html.Node, rule, title, url string) bool {
+	reason := ""
+	switch {
+	case title == "" && url == "":
+		reason = "no link"
+	case title == "":
+		reason = "empty title"
+	case url == "":
+		reason = "missing href"
+	}
+	if reason != "" {
+		t.reject(nodePath(n), rule, reason, title, url)
+		return false
+	}
+	if t != nil {
+		t(TraceEvent{Path: nodePath(n), Rule: rule, Outcome: TraceMatched, Title: title, URL: url})
+	}
+	return true
+}
+
+func (t Tracer) reject(path, rule, reason, title, url string) {
+	if t != nil {
+		t(TraceEvent{Path: path, Rule: rule, Outcome: TraceRejected, Reason: reason, Title: title, URL: url})
+	}
+}
+
+// rejectNode reports an element that looked like a candidate but did not match the rule
+func (t Tracer) rejectNode(n *html.Node, rule, reason string) {
+	if t != nil {
+		t.reject(nodePath(n), rule, reason, "", "")
+	}
+}
+
+// nodePath returns the path of an element from the root of the document as tag#id.class selectors
+func nodePath(n *html.Node) string {
+	var parts []string
+	for ; n != nil; n = n.Parent {
+		if n.Type != html.ElementNode {
+			continue
+		}
+		part := n.Data
+		if id := getAttr(n, "id"); id != "" {
+			part += "#" + id
+		}
+		for _, class := range strings.Fields(getAttr(n, "class")) {
+			part += "." + class
+		}
+		parts = append(parts, part)
+	}
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, " > ")
+}
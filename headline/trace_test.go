@@ -0,0 +1,61 @@
+package headline
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	_ TracingExtractor = (*MZaminClient)(nil)
+	_ TracingExtractor = (*ProthomAloClient)(nil)
+	_ TracingExtractor = (*DailyStarBanglaClient)(nil)
+	_ TracingExtractor = (*EmbeddedJSONClient)(nil)
+)
+
+func TestExtractHeadlinesTrace(t *testing.T) {
+	page := `<html><body>
+		<h1 class="title"><a href="/ignored">Not the lead</a></h1>
+		<div id="main" class="row news">
+			<h3><a href="/article1">Headline 1</a></h3>
+			<h3><a>Headline 2</a></h3>
+			<h3><a href="/article3"></a></h3>
+		</div>
+	</body></html>`
+
+	var events []TraceEvent
+	client := NewMZaminClient("http://mzamin.com", nil)
+	items, err := client.ExtractHeadlines(page, func(e TraceEvent) { events = append(events, e) })
+	if err != nil {
+		t.Fatalf("Error extracting headlines: %v", err)
+	}
+	if len(items) != 1 || items[0].Title != "Headline 1" {
+		t.Errorf("Expected 1 headline, got %+v", items)
+	}
+
+	expected := []TraceEvent{
+		{Path: "html > body > h1.title", Rule: "h1.display-3", Outcome: TraceRejected, Reason: "class is not display-3"},
+		{Path: "html > body > div#main.row.news > h3", Rule: "h3", Outcome: TraceMatched, Title: "Headline 1", URL: "http://mzamin.com/article1"},
+		{Path: "html > body > div#main.row.news > h3", Rule: "h3", Outcome: TraceRejected, Reason: "missing href", Title: "Headline 2"},
+		{Path: "html > body > div#main.row.news > h3", Rule: "h3", Outcome: TraceRejected, Reason: "empty title", URL: "http://mzamin.com/article3"},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Errorf("Trace events = %+v; want %+v", events, expected)
+	}
+}
+
+func TestEmbeddedJSONExtractTraced(t *testing.T) {
+	page := `<html><head><script id="__NEXT_DATA__" type="application/json">
+		{"stories": [{"h": "One", "u": "/1"}, {"h": "", "u": "/2"}, {"h": "Again", "u": "/1"}]}
+	</script></head></html>`
+
+	var reasons []string
+	trace := func(e TraceEvent) { reasons = append(reasons, e.Outcome+" "+e.Reason) }
+	extractor := EmbeddedJSON{Script: ScriptNextData, Items: "stories[*]", Title: "h", URL: "u"}
+	if _, err := extractor.ExtractTraced(page, "http://example.com", trace); err != nil {
+		t.Fatalf("Error extracting headlines: %v", err)
+	}
+	expected := []string{"matched ", "rejected empty title", "rejected duplicate url"}
+	if !reflect.DeepEqual(reasons, expected) {
+		t.Errorf("Trace outcomes = %q; want %q", reasons, expected)
+	}
+}
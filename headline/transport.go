@@ -0,0 +1,137 @@
+package headline
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// TransportOptions configures the network transport of a CachingHTTPClient
+type TransportOptions struct {
+	// Proxy is the URL of an http, https or socks5 proxy. When nil, the HTTP_PROXY, HTTPS_PROXY
+	// and NO_PROXY environment variables are used.
+	Proxy *url.URL
+	// CABundle is a PEM file of CA certificates trusted in addition to the system's
+	CABundle string
+	// MaxIdleConns is the maximum number of idle connections across all hosts. Zero means unlimited.
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the maximum number of idle connections kept per host. Zero uses the default of 2.
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limits the connections per host, including those in use. Zero means unlimited.
+	MaxConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept. Zero keeps it until it is closed.
+	IdleConnTimeout time.Duration
+	// DisableHTTP2 restricts the transport to HTTP/1.1
+	DisableHTTP2 bool
+}
+
+// WithTransport sets the transport requests are sent with, e.g. one created by NewTransport or
+// an offline transport
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *CachingHTTPClient) {
+		c.client.Transport = transport
+	}
+}
+
+// NewTransport creates a transport with the given options
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.Proxy != nil {
+		if err := validateProxy(opts.Proxy); err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(opts.Proxy)
+	}
+
+	if opts.CABundle != "" {
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	transport.MaxIdleConns = opts.MaxIdleConns
+	transport.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+	transport.MaxConnsPerHost = opts.MaxConnsPerHost
+	transport.IdleConnTimeout = opts.IdleConnTimeout
+	if opts.DisableHTTP2 {
+		// A non-nil empty map disables the automatic upgrade to HTTP/2
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	return transport, nil
+}
+
+func validateProxy(proxy *url.URL) error {
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q, use http, https or socks5", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return errors.New("proxy URL has no host")
+	}
+	return nil
+}
+
+// WithProxy returns a copy of the client that sends its requests through the proxy. The copy
+// shares the cache, limiters and robots.txt rules of the client. A client with a transport
+// other than *http.Transport, such as an offline transport, is not using the network and is
+// returned unchanged.
+func (c *CachingHTTPClient) WithProxy(proxy *url.URL) (*CachingHTTPClient, error) {
+	if err := validateProxy(proxy); err != nil {
+		return nil, err
+	}
+	var transport *http.Transport
+	switch t := c.client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		return c, nil
+	}
+	transport.Proxy = http.ProxyURL(proxy)
+
+	clone := *c
+	clone.client = &http.Client{
+		Timeout:       c.client.Timeout,
+		Transport:     transport,
+		CheckRedirect: c.client.CheckRedirect,
+	}
+	return &clone, nil
+}
+
+// WithUserAgents rotates the User-Agent header of the requests through the given user agents.
+// robots.txt rules are still matched against the client's own user agent.
+func WithUserAgents(userAgents []string) ClientOption {
+	return func(c *CachingHTTPClient) {
+		if len(userAgents) > 0 {
+			c.userAgents = append([]string(nil), userAgents...)
+			c.nextUserAgent = new(atomic.Uint64)
+		}
+	}
+}
+
+// requestUserAgent returns the User-Agent header of the next request
+func (c *CachingHTTPClient) requestUserAgent() string {
+	if len(c.userAgents) == 0 {
+		return c.userAgent
+	}
+	i := c.nextUserAgent.Add(1) - 1
+	return c.userAgents[i%uint64(len(c.userAgents))]
+}
@@ -0,0 +1,106 @@
+package headline
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithUserAgents(t *testing.T) {
+	var mu sync.Mutex
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents = append(agents, r.UserAgent())
+		mu.Unlock()
+		w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	client := NewCachingHTTPClient(time.Second, "headlines/1.0", WithUserAgents([]string{"agent-a", "agent-b"}))
+	for _, path := range []string{"/1", "/2", "/3"} {
+		if _, err := client.Fetch(context.Background(), server.URL+path); err != nil {
+			t.Fatalf("Error fetching page: %v", err)
+		}
+	}
+
+	want := []string{"agent-a", "agent-b", "agent-a"}
+	if len(agents) != len(want) {
+		t.Fatalf("Expected %d requests, got %v", len(want), agents)
+	}
+	for i := range want {
+		if agents[i] != want[i] {
+			t.Errorf("Expected the user agents to rotate as %v, got %v", want, agents)
+			break
+		}
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A forward proxy receives the absolute URL of the request
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte("<html>proxied</html>"))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	transport, err := NewTransport(TransportOptions{})
+	if err != nil {
+		t.Fatalf("Error creating transport: %v", err)
+	}
+	client := NewCachingHTTPClient(time.Second, "test-agent", WithTransport(transport))
+	proxiedClient, err := client.WithProxy(proxyURL)
+	if err != nil {
+		t.Fatalf("Error setting proxy: %v", err)
+	}
+
+	page, err := proxiedClient.Fetch(context.Background(), "http://news.example/")
+	if err != nil {
+		t.Fatalf("Error fetching through the proxy: %v", err)
+	}
+	if string(page.Body) != "<html>proxied</html>" || len(proxied) != 1 || proxied[0] != "http://news.example/" {
+		t.Errorf("Expected the request to go through the proxy, got %q and %v", page.Body, proxied)
+	}
+	if client.client.Transport != transport {
+		t.Error("Expected the original client to keep its transport")
+	}
+
+	if _, err := client.WithProxy(&url.URL{Scheme: "ftp", Host: "proxy.local"}); err == nil {
+		t.Error("Expected an error for an unsupported proxy scheme")
+	}
+
+	offline := NewCachingHTTPClient(time.Second, "test-agent", WithTransport(NewDirTransport(t.TempDir())))
+	if same, err := offline.WithProxy(proxyURL); err != nil || same != offline {
+		t.Errorf("Expected an offline client to be returned unchanged, got %v", err)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	transport, err := NewTransport(TransportOptions{MaxIdleConnsPerHost: 4, MaxConnsPerHost: 8, IdleConnTimeout: time.Minute, DisableHTTP2: true})
+	if err != nil {
+		t.Fatalf("Error creating transport: %v", err)
+	}
+	if transport.MaxIdleConnsPerHost != 4 || transport.MaxConnsPerHost != 8 || transport.IdleConnTimeout != time.Minute {
+		t.Errorf("Unexpected pool settings %+v", transport)
+	}
+	if transport.ForceAttemptHTTP2 || transport.TLSNextProto == nil {
+		t.Error("Expected HTTP/2 to be disabled")
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(bundle, []byte("not a certificate"), 0o644)
+	if _, err := NewTransport(TransportOptions{CABundle: bundle}); err == nil {
+		t.Error("Expected an error for a CA bundle without certificates")
+	}
+	if _, err := NewTransport(TransportOptions{Proxy: &url.URL{Scheme: "socks5"}}); err == nil {
+		t.Error("Expected an error for a proxy without host")
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+// encodedHeadlines is a snapshot of the headlines encoded once for all the requests it serves
+type encodedHeadlines struct {
+	body    []byte
+	gzipped []byte
+	// etag is the quoted hash of body
+	etag string
+	// modified is when the body last changed
+	modified time.Time
+}
+
+// headlinesEncoder keeps the encoded bodies of the cached headlines, per category, until the
+// cache is refreshed
+type headlinesEncoder struct {
+	mu       sync.Mutex
+	snapshot time.Time
+	bodies   map[string]*encodedHeadlines
+}
+
+// encode returns the encoded headlines of the category in the cached snapshot, encoding them on
+// the first request
+func (e *headlinesEncoder) encode(cached headline.CachedResponse, category string) (*encodedHeadlines, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	previous := e.bodies
+	if !cached.Timestamp.Equal(e.snapshot) {
+		if cached.Timestamp.Before(e.snapshot) {
+			// A request that read the cache before it was refreshed is served without evicting the newer snapshot
+			return encodeHeadlines(cached, category)
+		}
+		e.snapshot = cached.Timestamp
+		e.bodies = make(map[string]*encodedHeadlines)
+	}
+	if encoded, ok := e.bodies[category]; ok {
+		return encoded, nil
+	}
+
+	encoded, err := encodeHeadlines(cached, category)
+	if err != nil {
+		return nil, err
+	}
+	// Headlines unchanged by the refresh keep their modification time, so that clients
+	// revalidating with If-Modified-Since are not sent the same body again
+	if p, ok := previous[category]; ok && p.etag == encoded.etag {
+		encoded.modified = p.modified
+	}
+	e.bodies[category] = encoded
+	return encoded, nil
+}
+
+func encodeHeadlines(cached headline.CachedResponse, category string) (*encodedHeadlines, error) {
+	headlines := cached.Body
+	if category != "" {
+		headlines = headline.FilterCategory(headlines, category)
+	}
+	body, err := json.Marshal(headlines)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding headlines: %w", err)
+	}
+	body = append(body, '\n')
+
+	var gzipped bytes.Buffer
+	zw, _ := gzip.NewWriterLevel(&gzipped, gzip.BestCompression)
+	zw.Write(body)
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing headlines: %w", err)
+	}
+
+	sum := sha256.Sum256(body)
+	return &encodedHeadlines{
+		body:     body,
+		gzipped:  gzipped.Bytes(),
+		etag:     fmt.Sprintf(`"%x"`, sum[:16]),
+		modified: cached.Timestamp,
+	}, nil
+}
+
+// acceptsGzip returns whether the Accept-Encoding header of the request allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			name, params, _ := strings.Cut(coding, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "gzip" && name != "*" {
+				continue
+			}
+			q, _ := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+			if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+				continue
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// serveEncodedHeadlines serves the encoded headlines of a snapshot of the given age, answering
+// conditional requests with 304 Not Modified. The response may be cached for the ttl of the
+// snapshot, from which the Age header is deducted.
+func serveEncodedHeadlines(w http.ResponseWriter, r *http.Request, encoded *encodedHeadlines, age, ttl time.Duration) {
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Add("Vary", "Accept-Encoding")
+	if ttl > 0 {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(ttl.Seconds())))
+		h.Set("Age", strconv.Itoa(int(age.Seconds())))
+	} else {
+		h.Set("Cache-Control", "no-cache")
+	}
+
+	body, etag := encoded.body, encoded.etag
+	if acceptsGzip(r) {
+		// The representations have distinct tags, as a strong ETag identifies the exact bytes
+		body, etag = encoded.gzipped, strings.TrimSuffix(etag, `"`)+`-gzip"`
+		h.Set("Content-Encoding", "gzip")
+	}
+	h.Set("ETag", etag)
+	http.ServeContent(w, r, "", encoded.modified, bytes.NewReader(body))
+}
+
+// compressExcept compresses responses as middleware.Compress does, except those of the paths
+// that encode their own bodies. Compressing them again would send different bytes, e.g.
+// deflated to a client not accepting gzip, under the ETag of the identity body.
+func compressExcept(level int, paths ...string) func(http.Handler) http.Handler {
+	compress := middleware.Compress(level)
+	return func(next http.Handler) http.Handler {
+		compressed := compress(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(paths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			compressed.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+func TestHeadlinesHandlerConditional(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	sources := []headline.NewsClient{&MockNewsClient{
+		headlines: []headline.NewsItem{{Title: "Test 1", URL: "http://test1.com"}},
+	}}
+	aggregator := headline.NewAggregator(func() []headline.NewsClient { return sources },
+		headline.CachePolicy{Duration: time.Minute}, headline.WithClock(func() time.Time { return now }))
+	handler := headlinesHandler(aggregator)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/headlines", nil))
+	etag := rr.Header().Get("ETag")
+	if rr.Code != http.StatusOK || etag == "" || rr.Header().Get("Last-Modified") == "" {
+		t.Fatalf("Expected the headlines with an ETag and Last-Modified, got %d %v", rr.Code, rr.Header())
+	}
+	if cc := rr.Header().Get("Cache-Control"); cc != "public, max-age=60" || rr.Header().Get("Age") != "0" {
+		t.Errorf("Expected caching for the cache duration, got Cache-Control %q and Age %q", cc, rr.Header().Get("Age"))
+	}
+	body := rr.Body.String()
+
+	now = now.Add(10 * time.Second)
+	req := httptest.NewRequest("GET", "/api/headlines", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 || rr.Header().Get("X-Cache") != "HIT" {
+		t.Errorf("Expected 304 Not Modified from the cache, got %d with %q", rr.Code, rr.Body.String())
+	}
+	if age := rr.Header().Get("Age"); age != "10" {
+		t.Errorf("Expected the headlines to be 10s old, got %q", age)
+	}
+
+	req = httptest.NewRequest("GET", "/api/headlines", nil)
+	req.Header.Set("If-None-Match", `"outdated"`)
+	req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("ETag") == etag {
+		t.Fatalf("Expected the compressed headlines with their own ETag, got %d %v", rr.Code, rr.Header())
+	}
+	zr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("Error decompressing response: %v", err)
+	}
+	if decompressed, _ := io.ReadAll(zr); string(decompressed) != body {
+		t.Errorf("Expected the compressed body to be %q, got %q", body, decompressed)
+	}
+}
+
+func TestHeadlinesEncoder(t *testing.T) {
+	encoder := &headlinesEncoder{}
+	first := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	page := []headline.Response{{
+		Source:    headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{{Title: "Test 1", URL: "http://test1.com", Category: headline.CategorySports}},
+	}}
+
+	encoded, _ := encoder.encode(headline.CachedResponse{Body: page, Timestamp: first}, "")
+	if again, _ := encoder.encode(headline.CachedResponse{Body: page, Timestamp: first}, ""); again != encoded {
+		t.Error("Expected a snapshot to be encoded once")
+	}
+
+	// A refresh with the same headlines keeps the tag and modification time
+	refreshed, _ := encoder.encode(headline.CachedResponse{Body: page, Timestamp: first.Add(time.Minute)}, "")
+	if refreshed.etag != encoded.etag || !refreshed.modified.Equal(first) {
+		t.Errorf("Expected unchanged headlines to keep %s modified at %v, got %s at %v", encoded.etag, first, refreshed.etag, refreshed.modified)
+	}
+
+	changed := []headline.Response{{Source: page[0].Source, Headlines: []headline.NewsItem{{Title: "Test 2", URL: "http://test2.com"}}}}
+	updated, _ := encoder.encode(headline.CachedResponse{Body: changed, Timestamp: first.Add(2 * time.Minute)}, "")
+	if updated.etag == encoded.etag || !updated.modified.Equal(first.Add(2*time.Minute)) {
+		t.Errorf("Expected changed headlines to get a new tag and modification time, got %s at %v", updated.etag, updated.modified)
+	}
+
+	filtered, _ := encoder.encode(headline.CachedResponse{Body: changed, Timestamp: first.Add(2 * time.Minute)}, headline.CategorySports)
+	if filtered.etag == updated.etag {
+		t.Error("Expected each category to be encoded separately")
+	}
+}
+
+func TestCompressExcept(t *testing.T) {
+	sources := []headline.NewsClient{&MockNewsClient{
+		headlines: []headline.NewsItem{{Title: "Test 1", URL: "http://test1.com"}},
+	}}
+	aggregator := headline.NewAggregator(func() []headline.NewsClient { return sources }, headline.DefaultCachePolicy)
+	router := chi.NewRouter()
+	router.Use(compressExcept(5, "/api/headlines"))
+	router.Get("/api/headlines", headlinesHandler(aggregator))
+	router.Get("/api/sources", sourcesHandler(aggregator.Sources))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/headlines", nil))
+	identity := rr.Header().Get("ETag")
+
+	// A client only accepting deflate is sent the identity body its ETag was computed for
+	req := httptest.NewRequest("GET", "/api/headlines", nil)
+	req.Header.Set("Accept-Encoding", "deflate")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if encoding := rr.Header().Get("Content-Encoding"); encoding != "" || rr.Header().Get("ETag") != identity {
+		t.Errorf("Expected the identity body tagged %s, got %q tagged %s", identity, encoding, rr.Header().Get("ETag"))
+	}
+
+	req = httptest.NewRequest("GET", "/api/sources", nil)
+	req.Header.Set("Accept-Encoding", "deflate")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if encoding := rr.Header().Get("Content-Encoding"); encoding != "deflate" {
+		t.Errorf("Expected the other responses to be compressed, got %q", encoding)
+	}
+}
@@ -0,0 +1,521 @@
+// Package history stores the headlines seen on the front pages over time
+package history
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+// Observation is the list of headlines seen on a source's front page at a point in time.
+// Observations are only recorded when the page changed.
+type Observation struct {
+	At     time.Time           `json:"at"`
+	Source string              `json:"source"`
+	Items  []headline.NewsItem `json:"items"`
+
+	// lastSeen is when the page was last seen unchanged
+	lastSeen time.Time
+}
+
+// LastSeen returns when the page was last seen unchanged, which is At until it is seen again
+func (o Observation) LastSeen() time.Time {
+	if o.lastSeen.After(o.At) {
+		return o.lastSeen
+	}
+	return o.At
+}
+
+// record is a line of the history file: an observation, or a sighting of the source's unchanged
+// page when Seen is set
+type record struct {
+	Observation
+	Seen bool `json:"seen,omitempty"`
+}
+
+// sighting is the compact record of an unchanged page
+type sighting struct {
+	At     time.Time `json:"at"`
+	Source string    `json:"source"`
+	Seen   bool      `json:"seen"`
+}
+
+// Article is a story seen on a front page, identified by its canonical URL
+type Article struct {
+	ID       string `json:"id"`
+	Source   string `json:"source"`
+	URL      string `json:"url"`
+	Title    string `json:"title"`
+	Category string `json:"category,omitempty"`
+	// Rank and Prominence are as last seen
+	Rank       int    `json:"rank"`
+	Prominence string `json:"prominence"`
+	// BestRank and BestProminence are the highest placement the story ever had
+	BestRank       int       `json:"bestRank"`
+	BestProminence string    `json:"bestProminence"`
+	FirstSeen      time.Time `json:"firstSeen"`
+	LastSeen       time.Time `json:"lastSeen"`
+}
+
+// Store keeps the observations of the front pages in memory and, when opened with a path,
+// appends them to a JSON Lines file that is replayed on startup
+type Store struct {
+	retention time.Duration
+	path      string
+
+	mu           sync.RWMutex
+	file         *os.File
+	observations []Observation
+	latest       map[string]int
+	articles     map[string]*Article
+	// revisions holds the distinct titles of each article, oldest first
+	revisions map[string][]Revision
+	// version counts the refreshes recorded
+	version uint64
+}
+
+// Open opens the store persisted at path, creating it if necessary. An empty path keeps the
+// history in memory only. Observations older than retention are dropped; zero keeps them forever.
+func Open(path string, retention time.Duration) (*Store, error) {
+	s := newStore(path, retention)
+	if path == "" {
+		return s, nil
+	}
+
+	records, err := readRecords(path)
+	if err != nil {
+		return nil, err
+	}
+	s.replay(records)
+	s.prune(time.Now())
+
+	// Expired observations and superseded sightings are compacted away
+	if compacted := s.compacted(); len(compacted) < len(records) {
+		if err := writeRecords(path, compacted); err != nil {
+			return nil, err
+		}
+	}
+
+	s.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %v", err)
+	}
+	return s, nil
+}
+
+// Load reads the store persisted at path without modifying the file, so that the history of a
+// running server can be read. Nothing is dropped, and the refreshes recorded are not persisted.
+func Load(path string) (*Store, error) {
+	records, err := readRecords(path)
+	if err != nil {
+		return nil, err
+	}
+	s := newStore("", 0)
+	s.replay(records)
+	return s, nil
+}
+
+func newStore(path string, retention time.Duration) *Store {
+	return &Store{
+		retention: retention,
+		path:      path,
+		latest:    make(map[string]int),
+		articles:  make(map[string]*Article),
+		revisions: make(map[string][]Revision),
+	}
+}
+
+// replay applies the records of the history file in order
+func (s *Store) replay(records []record) {
+	for _, r := range records {
+		if r.Seen {
+			s.touch(r.Source, r.At)
+		} else {
+			s.apply(r.Observation)
+		}
+	}
+}
+
+func readRecords(path string) ([]record, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history: %v", err)
+	}
+	defer f.Close()
+
+	var records []record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("failed to read history %s line %d: %v", path, line, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read history %s: %v", path, err)
+	}
+	return records, nil
+}
+
+// compacted returns the records replaying the current state: each observation followed by the
+// last sighting of its page, which is all that is needed to restore when its articles were last seen
+func (s *Store) compacted() []any {
+	var records []any
+	for _, o := range s.observations {
+		records = append(records, o)
+		if o.lastSeen.After(o.At) {
+			records = append(records, sighting{At: o.lastSeen, Source: o.Source, Seen: true})
+		}
+	}
+	return records
+}
+
+func writeRecords(path string, records []any) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("failed to compact history: %v", err)
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to compact history: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to compact history: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to compact history: %v", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Close closes the history file
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *Store) cutoff(now time.Time) time.Time {
+	if s.retention <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-s.retention)
+}
+
+// Start returns when the articles of the store start being complete: the first observation, or
+// the retention cutoff once older articles have been dropped. It is zero for an empty store.
+func (s *Store) Start() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.observations) == 0 {
+		return time.Time{}
+	}
+	start := s.observations[0].At
+	if cutoff := s.cutoff(time.Now()); cutoff.After(start) {
+		return cutoff
+	}
+	return start
+}
+
+// Record stores the headlines fetched at the given time. Failed and stale responses are skipped
+// since they do not reflect the current front page.
+func (s *Store) Record(at time.Time, responses []headline.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, resp := range responses {
+		if !resp.Current() {
+			continue
+		}
+		source := resp.Source.ID
+		if source == "" {
+			source = resp.Source.Name
+		}
+		o := Observation{At: at, Source: source, Items: resp.Headlines}
+
+		// Unchanged pages only mark their articles as seen, which is persisted as a sighting
+		var line []byte
+		var err error
+		if i, ok := s.latest[source]; ok && reflect.DeepEqual(s.observations[i].Items, o.Items) {
+			s.touch(source, at)
+			line, err = json.Marshal(sighting{At: at, Source: source, Seen: true})
+		} else {
+			s.apply(o)
+			line, err = json.Marshal(o)
+		}
+		if s.file != nil {
+			if err == nil {
+				_, err = s.file.Write(append(line, '\n'))
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to write history: %v", err))
+			}
+		}
+	}
+	s.prune(at)
+	s.version++
+	return errors.Join(errs...)
+}
+
+// Version returns a number that changes whenever the store records a refresh, so that results
+// computed from the history can be kept until then
+func (s *Store) Version() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.version
+}
+
+// apply adds an observation to the in-memory state
+func (s *Store) apply(o Observation) {
+	o.lastSeen = o.At
+	s.latest[o.Source] = len(s.observations)
+	s.observations = append(s.observations, o)
+
+	seen := make(map[string]bool, len(o.Items))
+	for _, item := range o.Items {
+		id := ArticleID(item.URL)
+		// A story linked twice on a page is placed where it appears first
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		a, ok := s.articles[id]
+		if !ok {
+			a = &Article{ID: id, Source: o.Source, URL: item.URL, FirstSeen: o.At, BestRank: item.Rank, BestProminence: item.Prominence}
+			s.articles[id] = a
+		}
+		s.revise(id, item.Title, o.At)
+		a.Title = item.Title
+		a.Rank = item.Rank
+		a.Prominence = item.Prominence
+		a.LastSeen = o.At
+		if item.Category != "" {
+			a.Category = item.Category
+		}
+		if item.Rank > 0 && (a.BestRank == 0 || item.Rank < a.BestRank) {
+			a.BestRank = item.Rank
+		}
+		if headline.ProminenceWeight(item.Prominence) > headline.ProminenceWeight(a.BestProminence) {
+			a.BestProminence = item.Prominence
+		}
+	}
+}
+
+// touch marks the latest page of a source and its articles as seen at the given time
+func (s *Store) touch(source string, at time.Time) {
+	i, ok := s.latest[source]
+	if !ok {
+		return
+	}
+	s.observations[i].lastSeen = at
+	for _, item := range s.observations[i].Items {
+		id := ArticleID(item.URL)
+		if a, ok := s.articles[id]; ok {
+			a.LastSeen = at
+			if revisions := s.revisions[id]; len(revisions) > 0 {
+				revisions[len(revisions)-1].LastSeen = at
+			}
+		}
+	}
+}
+
+// prune drops the observations and articles that are older than the retention. An older
+// observation is kept while one of its articles is still seen, so that the first sighting and
+// the titles of the article survive the replay of the compacted file.
+func (s *Store) prune(now time.Time) {
+	cutoff := s.cutoff(now)
+	n := sort.Search(len(s.observations), func(i int) bool { return !s.observations[i].At.Before(cutoff) })
+	if n == 0 {
+		return
+	}
+	expired := make(map[int]bool)
+	for i, o := range s.observations[:n] {
+		if !s.seenSince(o, cutoff) {
+			expired[i] = true
+		}
+	}
+
+	if len(expired) > 0 {
+		kept := make([]Observation, 0, len(s.observations)-len(expired))
+		for i, o := range s.observations {
+			if !expired[i] {
+				kept = append(kept, o)
+			}
+		}
+		s.observations = kept
+		clear(s.latest)
+		for i, o := range s.observations {
+			s.latest[o.Source] = i
+		}
+	}
+	for id, a := range s.articles {
+		if a.LastSeen.Before(cutoff) {
+			delete(s.articles, id)
+			delete(s.revisions, id)
+		}
+	}
+}
+
+// seenSince returns whether the page of the observation or one of its articles was seen since the given time
+func (s *Store) seenSince(o Observation, since time.Time) bool {
+	if !o.lastSeen.Before(since) {
+		return true
+	}
+	for _, item := range o.Items {
+		if a, ok := s.articles[ArticleID(item.URL)]; ok && !a.LastSeen.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// Observations returns the observations of a source, or of all sources if source is empty,
+// recorded between from and to inclusive. A zero time leaves that end open.
+func (s *Store) Observations(source string, from, to time.Time) []Observation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var observations []Observation
+	for _, o := range s.observations {
+		if (source != "" && o.Source != source) || (!from.IsZero() && o.At.Before(from)) || (!to.IsZero() && o.At.After(to)) {
+			continue
+		}
+		observations = append(observations, o)
+	}
+	return observations
+}
+
+// Articles returns the articles seen since the given time, most recently seen first
+func (s *Store) Articles(since time.Time) []Article {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var articles []Article
+	for _, a := range s.articles {
+		if !a.LastSeen.Before(since) {
+			articles = append(articles, *a)
+		}
+	}
+	sort.Slice(articles, func(i, j int) bool {
+		if !articles[i].LastSeen.Equal(articles[j].LastSeen) {
+			return articles[i].LastSeen.After(articles[j].LastSeen)
+		}
+		return articles[i].ID < articles[j].ID
+	})
+	return articles
+}
+
+// EachArticle calls fn with the articles of a source, or of all sources if source is empty,
+// that were seen between from and to inclusive, in the order they were first seen. A zero time
+// leaves that end open. The articles are read one at a time, so a slow fn does not block the
+// recording of new observations. Iteration stops at the first error returned by fn.
+func (s *Store) EachArticle(source string, from, to time.Time, fn func(Article) error) error {
+	type key struct {
+		id        string
+		firstSeen time.Time
+	}
+	s.mu.RLock()
+	var keys []key
+	for _, a := range s.articles {
+		if (source != "" && a.Source != source) || (!from.IsZero() && a.LastSeen.Before(from)) || (!to.IsZero() && a.FirstSeen.After(to)) {
+			continue
+		}
+		keys = append(keys, key{a.ID, a.FirstSeen})
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool {
+		if !keys[i].firstSeen.Equal(keys[j].firstSeen) {
+			return keys[i].firstSeen.Before(keys[j].firstSeen)
+		}
+		return keys[i].id < keys[j].id
+	})
+	for _, k := range keys {
+		// Articles pruned in the meantime are skipped
+		a, ok := s.Article(k.id)
+		if !ok {
+			continue
+		}
+		if err := fn(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Article returns the article with the given ID
+func (s *Store) Article(id string) (Article, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.articles[id]
+	if !ok {
+		return Article{}, false
+	}
+	return *a, true
+}
+
+// ArticleID returns the ID of the article at the given URL
+func ArticleID(rawURL string) string {
+	sum := sha256.Sum256([]byte(CanonicalURL(rawURL)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// CanonicalURL normalizes an article URL so that links to the same article compare equal.
+// The scheme and host are lowercased, and the fragment, tracking parameters and trailing
+// slashes are removed.
+func CanonicalURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	if u.RawQuery != "" {
+		query := u.Query()
+		for key := range query {
+			if strings.HasPrefix(key, "utm_") || key == "fbclid" || key == "gclid" {
+				query.Del(key)
+			}
+		}
+		u.RawQuery = query.Encode()
+	}
+	if u.Path != "/" {
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		u.RawPath = ""
+	}
+	return u.String()
+}
@@ -0,0 +1,261 @@
+package history
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+func response(source string, items ...headline.NewsItem) headline.Response {
+	return headline.Response{Source: headline.SourceInfo{ID: source}, Headlines: items}
+}
+
+func TestStoreRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := Open(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	lead := headline.NewsItem{Title: "Lead", URL: "http://test.com/1", Rank: 1, Prominence: headline.ProminenceLead}
+	other := headline.NewsItem{Title: "Other", URL: "http://test.com/2", Rank: 2, Prominence: headline.ProminenceTop}
+
+	store.Record(start, []headline.Response{response("test", lead, other)})
+	// An unchanged page only updates when the articles were last seen
+	store.Record(start.Add(time.Minute), []headline.Response{response("test", lead, other)})
+	// Failed and stale responses are ignored
+	store.Record(start.Add(2*time.Minute), []headline.Response{{Source: headline.SourceInfo{ID: "test"}, Stale: true}})
+
+	demoted := lead
+	demoted.Rank, demoted.Prominence = 2, headline.ProminenceTop
+	other.Rank, other.Prominence = 1, headline.ProminenceLead
+	store.Record(start.Add(3*time.Minute), []headline.Response{response("test", other, demoted)})
+
+	if observations := store.Observations("test", time.Time{}, time.Time{}); len(observations) != 2 {
+		t.Fatalf("Expected 2 observations, got %d", len(observations))
+	}
+
+	a, ok := store.Article(ArticleID("http://test.com/1"))
+	if !ok {
+		t.Fatal("Expected the lead article to be stored")
+	}
+	if a.Rank != 2 || a.BestRank != 1 || a.BestProminence != headline.ProminenceLead {
+		t.Errorf("Expected rank 2 with best rank 1 as lead, got %+v", a)
+	}
+	if !a.FirstSeen.Equal(start) || !a.LastSeen.Equal(start.Add(3*time.Minute)) {
+		t.Errorf("Expected the article to be seen from %s to %s, got %s to %s", start, start.Add(3*time.Minute), a.FirstSeen, a.LastSeen)
+	}
+	store.Close()
+
+	// The history is replayed from the file
+	reopened, err := Open(path, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("Error reopening store: %v", err)
+	}
+	defer reopened.Close()
+	if articles := reopened.Articles(time.Time{}); len(articles) != 2 || articles[0].BestRank != 1 {
+		t.Errorf("Expected 2 articles from the file, got %+v", articles)
+	}
+}
+
+func TestStoreSightings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := Open(path, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+
+	start := time.Now().Add(-3 * time.Hour).Truncate(time.Second)
+	lead := headline.NewsItem{Title: "Lead", URL: "http://test.com/1"}
+	dropped := headline.NewsItem{Title: "Dropped", URL: "http://test.com/2"}
+	store.Record(start, []headline.Response{response("test", lead, dropped)})
+	store.Record(start.Add(time.Minute), []headline.Response{response("test", lead, dropped)})
+	store.Record(start.Add(2*time.Minute), []headline.Response{response("test", lead)})
+	// The page is unchanged for longer than the retention
+	for at := start.Add(time.Hour); at.Before(time.Now()); at = at.Add(time.Hour) {
+		store.Record(at, []headline.Response{response("test", lead)})
+	}
+	observations := store.Observations("test", time.Time{}, time.Time{})
+	lastSeen := observations[len(observations)-1].lastSeen
+	store.Close()
+
+	for i := 0; i < 2; i++ {
+		// The sightings are replayed, and kept when the file is compacted on the first reopening
+		reopened, err := Open(path, 2*time.Hour)
+		if err != nil {
+			t.Fatalf("Error reopening store: %v", err)
+		}
+		a, ok := reopened.Article(ArticleID(lead.URL))
+		if !ok || !a.LastSeen.Equal(lastSeen) || !a.FirstSeen.Equal(start) {
+			t.Errorf("Expected the article still on the page to be seen from %s to %s, got %+v", start, lastSeen, a)
+		}
+		if _, ok := reopened.Article(ArticleID(dropped.URL)); ok {
+			t.Error("Expected the article that left the page before the retention to be dropped")
+		}
+		reopened.Close()
+	}
+
+	records, err := readRecords(path)
+	if err != nil {
+		t.Fatalf("Error reading history: %v", err)
+	}
+	// The first page is kept for the article still on the page, and each page is followed by its last sighting
+	if len(records) != 4 || records[0].Seen || !records[1].Seen || records[2].Seen || !records[3].At.Equal(lastSeen) {
+		t.Errorf("Expected both pages with their last sighting, got %+v", records)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	store, err := Open(path, 0)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	lead := headline.NewsItem{Title: "Lead", URL: "http://test.com/1"}
+	for i := 0; i < 3; i++ {
+		store.Record(start.Add(time.Duration(i)*time.Minute), []headline.Response{response("test", lead)})
+	}
+	store.Close()
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Error loading store: %v", err)
+	}
+	if a, ok := loaded.Article(ArticleID(lead.URL)); !ok || !a.LastSeen.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("Expected the article to be seen until the last sighting, got %+v", a)
+	}
+	// The superseded sighting is not compacted away
+	if records, _ := readRecords(path); len(records) != 3 {
+		t.Errorf("Expected the file to be left untouched, got %d records", len(records))
+	}
+}
+
+func TestStoreRetention(t *testing.T) {
+	store, err := Open("", time.Hour)
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+
+	now := time.Now()
+	store.Record(now.Add(-2*time.Hour), []headline.Response{response("test", headline.NewsItem{Title: "Old", URL: "http://test.com/old"})})
+	store.Record(now, []headline.Response{response("other", headline.NewsItem{Title: "New", URL: "http://test.com/new"})})
+
+	if observations := store.Observations("", time.Time{}, time.Time{}); len(observations) != 1 || observations[0].Source != "other" {
+		t.Errorf("Expected only the recent observation, got %+v", observations)
+	}
+	if _, ok := store.Article(ArticleID("http://test.com/old")); ok {
+		t.Error("Expected the expired article to be dropped")
+	}
+	if start := store.Start(); start.Before(now.Add(-time.Hour)) || start.After(now) {
+		t.Errorf("Expected the history to start at the retention cutoff, got %v", start)
+	}
+
+	store, _ = Open("", 0)
+	if start := store.Start(); !start.IsZero() {
+		t.Errorf("Expected an empty history not to start, got %v", start)
+	}
+	store.Record(now, []headline.Response{response("test", headline.NewsItem{Title: "New", URL: "http://test.com/new"})})
+	if start := store.Start(); !start.Equal(now) {
+		t.Errorf("Expected the history to start at the first observation %v, got %v", now, start)
+	}
+}
+
+func TestStoreEachArticle(t *testing.T) {
+	store, _ := Open("", 0)
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	first := headline.NewsItem{Title: "First", URL: "http://test.com/1", Rank: 1}
+	second := headline.NewsItem{Title: "Second", URL: "http://test.com/2", Rank: 1}
+	store.Record(start, []headline.Response{response("test", first), response("other", headline.NewsItem{Title: "Elsewhere", URL: "http://other.com/1"})})
+	store.Record(start.Add(time.Minute), []headline.Response{response("test", second, first)})
+	store.Record(start.Add(2*time.Minute), []headline.Response{response("test", second)})
+
+	var titles []string
+	collect := func(a Article) error {
+		titles = append(titles, a.Title)
+		return nil
+	}
+	store.EachArticle("test", time.Time{}, time.Time{}, collect)
+	if len(titles) != 2 || titles[0] != "First" || titles[1] != "Second" {
+		t.Errorf("Expected the articles of the source in the order they were first seen, got %v", titles)
+	}
+
+	// The first article was last seen a minute in, the second one first seen a minute in
+	titles = nil
+	store.EachArticle("", start.Add(90*time.Second), time.Time{}, collect)
+	if len(titles) != 1 || titles[0] != "Second" {
+		t.Errorf("Expected only the article seen after from, got %v", titles)
+	}
+	titles = nil
+	store.EachArticle("", time.Time{}, start.Add(30*time.Second), collect)
+	if len(titles) != 2 {
+		t.Errorf("Expected only the articles first seen before to, got %v", titles)
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+	err := store.EachArticle("", time.Time{}, time.Time{}, func(Article) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected the iteration to stop at the first error, got %v after %d calls", err, calls)
+	}
+}
+
+func TestCanonicalURL(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+	}{
+		{"HTTPS://Example.com/news/1/", "https://example.com/news/1"},
+		{"https://example.com/news/1#comments", "https://example.com/news/1"},
+		{"https://example.com/news.php?news=1&utm_source=fb", "https://example.com/news.php?news=1"},
+		{"https://example.com/", "https://example.com/"},
+	}
+
+	for _, tc := range testCases {
+		if got := CanonicalURL(tc.url); got != tc.expected {
+			t.Errorf("CanonicalURL(%q) = %q; want %q", tc.url, got, tc.expected)
+		}
+	}
+}
+
+func TestStoreRevisions(t *testing.T) {
+	store, _ := Open("", 0)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := func(offset time.Duration, titles ...string) {
+		var items []headline.NewsItem
+		for _, title := range titles {
+			items = append(items, headline.NewsItem{Title: title, URL: "http://test.com/1"})
+		}
+		store.Record(start.Add(offset), []headline.Response{response("test", items...)})
+	}
+
+	record(0, "Ten killed in road accident")
+	// A second link to the same article does not count as an edit
+	record(time.Minute, "Ten killed in road accident", "Road accident")
+	record(2*time.Minute, "Twelve killed in road accident in Dhaka")
+	record(3*time.Minute, "Ten killed in road accident")
+
+	id := ArticleID("http://test.com/1")
+	revisions, ok := store.Revisions(id)
+	if !ok || len(revisions) != 3 {
+		t.Fatalf("Expected 3 revisions, got %+v", revisions)
+	}
+	if revisions[0].Title != "Ten killed in road accident" || !revisions[0].LastSeen.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected the first title to be seen until %s, got %+v", start.Add(time.Minute), revisions[0])
+	}
+
+	edits := store.Edits(start.Add(2 * time.Minute))
+	if len(edits) != 2 || edits[0].Title != "Ten killed in road accident" || edits[1].PreviousTitle != "Ten killed in road accident" {
+		t.Errorf("Expected 2 edits, newest first, got %+v", edits)
+	}
+	if edits := store.Edits(start.Add(time.Hour)); len(edits) != 0 {
+		t.Errorf("Expected no recent edits, got %+v", edits)
+	}
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"sort"
+	"time"
+)
+
+// Revision is a distinct title of an article and when it was shown
+type Revision struct {
+	Title     string    `json:"title"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Edit is a change of an article's title
+type Edit struct {
+	Article       Article   `json:"article"`
+	PreviousTitle string    `json:"previousTitle"`
+	Title         string    `json:"title"`
+	EditedAt      time.Time `json:"editedAt"`
+}
+
+// revise records the title of an article seen at the given time
+func (s *Store) revise(id, title string, at time.Time) {
+	revisions := s.revisions[id]
+	if n := len(revisions); n > 0 && revisions[n-1].Title == title {
+		revisions[n-1].LastSeen = at
+		return
+	}
+	s.revisions[id] = append(revisions, Revision{Title: title, FirstSeen: at, LastSeen: at})
+}
+
+// Revisions returns the distinct titles of an article, oldest first. A title the article
+// returns to after an edit is a new revision.
+func (s *Store) Revisions(id string) ([]Revision, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	revisions, ok := s.revisions[id]
+	if !ok {
+		return nil, false
+	}
+	return append([]Revision(nil), revisions...), true
+}
+
+// Edits returns the title changes made since the given time, newest first
+func (s *Store) Edits(since time.Time) []Edit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var edits []Edit
+	for id, revisions := range s.revisions {
+		for i := len(revisions) - 1; i > 0 && !revisions[i].FirstSeen.Before(since); i-- {
+			edits = append(edits, Edit{
+				Article:       *s.articles[id],
+				PreviousTitle: revisions[i-1].Title,
+				Title:         revisions[i].Title,
+				EditedAt:      revisions[i].FirstSeen,
+			})
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		if !edits[i].EditedAt.Equal(edits[j].EditedAt) {
+			return edits[i].EditedAt.After(edits[j].EditedAt)
+		}
+		return edits[i].Article.ID < edits[j].Article.ID
+	})
+	return edits
+}
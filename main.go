@@ -1,46 +1,130 @@
 package main
 
 import (
+	"context"
 	"embed"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/shaharia-lab/headlines/archive"
+	"github.com/shaharia-lab/headlines/config"
+	"github.com/shaharia-lab/headlines/graph"
 	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
 )
 
 //go:embed frontend.html
 var content embed.FS
 
+// shutdownTimeout is how long the requests in flight are waited for when the server stops
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	// Define the port flag
-	port := flag.Int("port", 8080, "Port to run the server on")
-	flag.Parse()
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "fetch":
+			os.Exit(runFetch(args[1:], os.Stdout))
+		case "debug":
+			os.Exit(runDebug(args[1:], os.Stdout))
+		case "export":
+			os.Exit(runExport(args[1:], os.Stdout))
+		case "serve":
+			args = args[1:]
+		}
+	}
+
+	cfg, opts, err := loadConfig(args)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	if opts.printConfig {
+		out, err := cfg.YAML()
+		if err != nil {
+			log.Fatalf("Failed to encode configuration: %v", err)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	httpClient, err := newHTTPClient(cfg.Scraper, cfg.Cache)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	var pages *archive.Archive
+	if cfg.Archive.Enabled {
+		pages, err = archive.Open(cfg.Archive.Dir, time.Duration(cfg.Archive.Retention))
+		if err != nil {
+			log.Fatalf("Failed to open archive: %v", err)
+		}
+		defer pages.Close()
+	}
 
-	httpClient := headline.NewCachingHTTPClient(5*time.Second, "headlines/1.0")
+	sources, err := newSourceSet(cfg, func() (*config.Config, error) {
+		reloaded, _, err := loadConfig(args)
+		return reloaded, err
+	}, httpClient, pages)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
 
-	sources := []headline.NewsClient{
-		headline.NewProthomAloClient("https://www.prothomalo.com/", httpClient),
-		headline.NewMZaminClient("https://mzamin.com/", httpClient),
-		headline.NewDailyStarBanglaClient("https://bangla.thedailystar.net/", httpClient),
+	store, err := history.Open(cfg.History.Path, time.Duration(cfg.History.Retention))
+	if err != nil {
+		log.Fatalf("Failed to open history: %v", err)
+	}
+	defer store.Close()
+
+	aggregator := headline.NewAggregator(sources.Sources, headline.CachePolicy{
+		Duration:    time.Duration(cfg.Cache.Duration),
+		StaleMaxAge: time.Duration(cfg.Cache.StaleMaxAge),
+	})
+
+	// SIGINT and SIGTERM stop the server gracefully, so that the history and archive are closed
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	feed := graph.NewFeed()
+	headlinesPoller := newPoller(aggregator, time.Duration(cfg.Cache.Duration), store, pages, feed)
+	sources.OnChange(func() {
+		aggregator.ClearCachedHeadlines()
+		headlinesPoller.Trigger()
+	})
+	polled := make(chan struct{})
+	go func() {
+		headlinesPoller.Run(ctx)
+		close(polled)
+	}()
+	go sources.WatchSignals(ctx)
+	if opts.configPath != "" && cfg.Server.ConfigWatchInterval > 0 {
+		go sources.WatchFile(ctx, opts.configPath, time.Duration(cfg.Server.ConfigWatchInterval))
 	}
 
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Compress(5))
+	r.Use(compressExcept(5, "/api/headlines"))
 
 	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedOrigins:   cfg.Server.AllowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "If-None-Match", "If-Modified-Since"},
+		ExposedHeaders:   []string{"Link", "Content-Disposition", "ETag", "Last-Modified", "Age"},
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -48,10 +132,274 @@ func main() {
 	// Serve the index.html file for the root route
 	r.Get("/", serveIndexHandler())
 
-	r.Get("/api/headlines", headlinesHandler(sources))
+	lifecycles := newLifecycleReplay(store)
+	r.Route("/api/v1", apiV1Routes(aggregator, store, lifecycles, pages))
+
+	// The unversioned API is kept for existing clients and links to its successor
+	r.Group(func(r chi.Router) {
+		r.Use(successorVersion)
+		r.Get("/api/headlines", headlinesHandler(aggregator))
+		r.Get("/api/sources", sourcesHandler(sources.Sources))
+		r.Get("/api/top", topHandler(store))
+		r.Get("/api/trends", trendsHandler(store))
+		r.Get("/api/articles/{id}/revisions", articleRevisionsHandler(store))
+		r.Get("/api/edits", editsHandler(store))
+		r.Get("/api/lifecycles", lifecyclesHandler(lifecycles))
+		r.Get("/api/dwell", dwellHandler(lifecycles))
+		r.Get("/api/snapshots", snapshotsHandler(pages, sources.Sources))
+	})
+	r.Get("/api/export", exportHandler(store))
+	r.Get("/api/snapshots/pages/{hash}", snapshotPageHandler(pages))
+
+	schema, err := graph.NewSchema(graphData(aggregator, store, pages, feed))
+	if err != nil {
+		log.Fatalf("Failed to create GraphQL schema: %v", err)
+	}
+	r.Get("/graphql", graph.Handler(schema))
+	r.Post("/graphql", graph.Handler(schema))
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.Server.Port))
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
+	}
+	server := &http.Server{
+		Handler: r,
+		// Requests see the shutdown through their context, which ends the subscriptions
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	log.Printf("Starting server on :%d", cfg.Server.Port)
+	err = serve(ctx, server, listener, shutdownTimeout)
+	stop()
+	// The poller may be recording a refresh, which must complete before the history is closed
+	<-polled
+	if err != nil {
+		store.Close()
+		if pages != nil {
+			pages.Close()
+		}
+		log.Fatalf("Server failed: %v", err)
+	}
+	log.Printf("Server stopped")
+}
+
+// serve serves the requests of the listener until ctx is done, then shuts the server down,
+// waiting up to timeout for the requests in flight
+func serve(ctx context.Context, server *http.Server, listener net.Listener, timeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() {
+		served <- server.Serve(listener)
+	}()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("failed to shut down: %w", err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// cliOptions are the command-line options that are not part of the configuration
+type cliOptions struct {
+	configPath  string
+	printConfig bool
+}
+
+// loadConfig resolves the configuration of the server from the config file, the environment and the command-line flags
+func loadConfig(args []string) (*config.Config, cliOptions, error) {
+	return parseConfig(flag.NewFlagSet("headlines", flag.ExitOnError), args)
+}
+
+// parseConfig defines the configuration flags on fs, which may define flags of its own, parses
+// args and resolves the configuration
+func parseConfig(fs *flag.FlagSet, args []string) (*config.Config, cliOptions, error) {
+	configPath := fs.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "Path to a YAML or TOML config file")
+	printConfig := fs.Bool("print-config", false, "Print the effective configuration and exit")
+	port := fs.Int("port", 0, "Port to run the server on")
+	cacheDuration := fs.Duration("cache-duration", 0, "How long aggregated headlines are cached")
+	timeout := fs.Duration("timeout", 0, "HTTP timeout for fetching news sources")
+	userAgent := fs.String("user-agent", "", "User agent used to fetch news sources")
+	sourceIDs := fs.String("sources", "", "Comma separated list of enabled source IDs")
+	offline := fs.String("offline", "", "Serve the pages from a directory of saved pages or a .har recording instead of the network")
+	if err := fs.Parse(args); err != nil {
+		return nil, cliOptions{}, err
+	}
+	opts := cliOptions{configPath: *configPath, printConfig: *printConfig}
+
+	cfg, err := config.Load(*configPath, os.LookupEnv)
+	if err != nil {
+		return nil, opts, err
+	}
+
+	// Flags take precedence over the file and the environment, but only when explicitly set
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "cache-duration":
+			cfg.Cache.Duration = config.Duration(*cacheDuration)
+		case "timeout":
+			cfg.Scraper.Timeout = config.Duration(*timeout)
+		case "user-agent":
+			cfg.Scraper.UserAgent = *userAgent
+		case "sources":
+			if e := cfg.EnableOnly(strings.Split(*sourceIDs, ",")); e != nil {
+				err = e
+			}
+		case "offline":
+			cfg.Scraper.Offline = config.OfflineConfig{Dir: *offline}
+			if strings.EqualFold(filepath.Ext(*offline), ".har") {
+				cfg.Scraper.Offline = config.OfflineConfig{HAR: *offline}
+			}
+		}
+	})
+	if err != nil {
+		return nil, opts, err
+	}
 
-	log.Printf("Starting server on :%d", *port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", *port), r))
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, err
+	}
+	return cfg, opts, nil
+}
+
+// newHTTPClient creates the HTTP client shared by all sources
+func newHTTPClient(scraper config.ScraperConfig, cache config.CacheConfig) (*headline.CachingHTTPClient, error) {
+	opts := []headline.ClientOption{
+		headline.WithCacheTTL(time.Duration(cache.HTTPTTL)),
+	}
+	switch {
+	case scraper.Offline.HAR != "":
+		transport, err := headline.LoadHARTransport(scraper.Offline.HAR)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, headline.WithTransport(transport))
+	case scraper.Offline.Dir != "":
+		opts = append(opts, headline.WithTransport(headline.NewDirTransport(scraper.Offline.Dir)))
+	default:
+		transport, err := newTransport(scraper.Transport)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, headline.WithTransport(transport))
+		// Recorded pages are served as fast as they are read, politeness only matters online
+		opts = append(opts, headline.WithRateLimit(headline.RateLimit{
+			RequestsPerSecond: scraper.RateLimit.RequestsPerSecond,
+			Burst:             scraper.RateLimit.Burst,
+			MaxConcurrent:     scraper.RateLimit.MaxConcurrentPerHost,
+			MinDelay:          time.Duration(scraper.RateLimit.MinDelay),
+			MaxRetryAfter:     time.Duration(scraper.RateLimit.MaxRetryAfter),
+		}))
+	}
+	if scraper.Robots.Enabled {
+		opts = append(opts, headline.WithRobots(time.Duration(scraper.Robots.TTL)))
+	}
+	if scraper.Retry.MaxAttempts > 1 {
+		opts = append(opts, headline.WithRetry(headline.RetryPolicy{
+			MaxAttempts:    scraper.Retry.MaxAttempts,
+			InitialBackoff: time.Duration(scraper.Retry.InitialBackoff),
+			MaxBackoff:     time.Duration(scraper.Retry.MaxBackoff),
+			Jitter:         scraper.Retry.Jitter,
+		}))
+	}
+	if len(scraper.UserAgents) > 0 {
+		opts = append(opts, headline.WithUserAgents(scraper.UserAgents))
+	}
+	return headline.NewCachingHTTPClient(time.Duration(scraper.Timeout), scraper.UserAgent, opts...), nil
+}
+
+// newTransport creates the network transport of the HTTP client
+func newTransport(tc config.TransportConfig) (*http.Transport, error) {
+	opts := headline.TransportOptions{
+		CABundle:            tc.CABundle,
+		MaxIdleConns:        tc.MaxIdleConns,
+		MaxIdleConnsPerHost: tc.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     tc.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(tc.IdleConnTimeout),
+		DisableHTTP2:        !tc.HTTP2,
+	}
+	if tc.Proxy != "" {
+		proxy, err := url.Parse(tc.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy: %v", err)
+		}
+		opts.Proxy = proxy
+	}
+	return headline.NewTransport(opts)
+}
+
+// sourceHTTPClient applies the robots.txt and proxy settings of a source to the shared HTTP client
+func sourceHTTPClient(sc config.SourceConfig, httpClient *headline.CachingHTTPClient) (*headline.CachingHTTPClient, error) {
+	client := httpClient
+	if sc.IgnoreRobots {
+		client = client.WithoutRobots()
+	}
+	if sc.Proxy != "" {
+		proxy, err := url.Parse(sc.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: invalid proxy: %v", sc.ID, err)
+		}
+		if client, err = client.WithProxy(proxy); err != nil {
+			return nil, fmt.Errorf("source %s: %w", sc.ID, err)
+		}
+	}
+	return client, nil
+}
+
+// newSource creates the news client for a configured source from the source registry
+func newSource(sc config.SourceConfig, httpClient *headline.CachingHTTPClient, breaker config.CircuitBreakerConfig, pages *archive.Archive) (headline.NewsClient, error) {
+	client, err := sourceHTTPClient(sc, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	if pages != nil {
+		client = client.WithPageHook(pages.PageHook(sc.ID))
+	}
+	source, err := headline.New(sc.SourceType(), headline.Options{
+		ID:         sc.ID,
+		URL:        sc.URL,
+		HTTPClient: client,
+		Params:     sc.Options,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Section pages are scraped by the same source type
+	sections := make([]headline.Section, 0, len(sc.Sections))
+	for _, section := range sc.Sections {
+		sectionSource, err := headline.New(sc.SourceType(), headline.Options{
+			ID:         sc.ID,
+			URL:        section.URL,
+			HTTPClient: client,
+			Params:     sc.Options,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, headline.Section{Category: section.Category, Client: sectionSource})
+	}
+	source, err = headline.NewCategorizedClient(source, sections)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", sc.ID, err)
+	}
+
+	if breaker.Enabled {
+		source = headline.NewCircuitBreakerClient(source, headline.BreakerSettings{
+			FailureThreshold: breaker.FailureThreshold,
+			OpenTimeout:      time.Duration(breaker.OpenTimeout),
+		})
+	}
+	return source, nil
 }
 
 func serveIndexHandler() http.HandlerFunc {
@@ -66,22 +414,104 @@ func serveIndexHandler() http.HandlerFunc {
 	}
 }
 
-func headlinesHandler(sources []headline.NewsClient) http.HandlerFunc {
+// categoryParam parses the optional category query parameter, in English or Bengali
+func categoryParam(r *http.Request) (string, error) {
+	category := r.URL.Query().Get("category")
+	if category == "" {
+		return "", nil
+	}
+	if category = headline.NormalizeCategory(category); category == "" {
+		return "", fmt.Errorf("Unknown category, expected one of %s", strings.Join(headline.Categories(), ", "))
+	}
+	return category, nil
+}
+
+// headlinesHandler serves the headlines of every source. The JSON of each snapshot of the cache
+// is encoded and compressed once, and clients polling with If-None-Match or If-Modified-Since
+// are answered with 304 Not Modified until the headlines change.
+func headlinesHandler(aggregator *headline.Aggregator) http.HandlerFunc {
+	encoder := &headlinesEncoder{}
 	return func(w http.ResponseWriter, r *http.Request) {
-		cachedHeadlines, isCached := headline.GetCachedHeadlines()
+		category, err := categoryParam(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cached, isCached := aggregator.Headlines()
+		encoded, err := encoder.encode(cached, category)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
 		if isCached {
-			w.Header().Set("Content-Type", "application/json")
 			w.Header().Set("X-Cache", "HIT")
-			json.NewEncoder(w).Encode(cachedHeadlines)
-			return
+		} else {
+			w.Header().Set("X-Cache", "MISS")
 		}
+		serveEncodedHeadlines(w, r, encoded, aggregator.Age(cached), aggregator.CacheDuration())
+	}
+}
 
-		headlines := headline.GetHeadlines(sources)
+// sourceStatus describes a registered news source and whether it is enabled
+type sourceStatus struct {
+	headline.SourceInfo
+	Enabled bool `json:"enabled"`
+}
 
-		headline.CacheHeadlines(headlines)
+// registeredSourceInfo returns the information about a registered source that is not enabled.
+// Generic sources cannot be created without options and are described by their ID only.
+func registeredSourceInfo(id string) headline.SourceInfo {
+	if source, err := headline.New(id, headline.Options{}); err == nil {
+		return source.SourceInfo()
+	}
+	return headline.SourceInfo{ID: id, Name: id}
+}
 
+// sourceStatuses lists the registered sources, the enabled ones first in the configured order
+func sourceStatuses(sources func() []headline.NewsClient) []sourceStatus {
+	var statuses []sourceStatus
+	enabled := make(map[string]bool)
+
+	for _, source := range sources() {
+		info := source.SourceInfo()
+		enabled[info.ID] = true
+		statuses = append(statuses, sourceStatus{SourceInfo: info, Enabled: true})
+	}
+
+	for _, id := range headline.Available() {
+		if enabled[id] {
+			continue
+		}
+		statuses = append(statuses, sourceStatus{SourceInfo: registeredSourceInfo(id), Enabled: false})
+	}
+	return statuses
+}
+
+func sourcesHandler(sources func() []headline.NewsClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Cache", "MISS")
-		json.NewEncoder(w).Encode(headlines)
+		json.NewEncoder(w).Encode(sourceStatuses(sources))
+	}
+}
+
+// graphData exposes the data of the REST handlers to the GraphQL schema
+func graphData(aggregator *headline.Aggregator, store *history.Store, pages *archive.Archive, feed *graph.Feed) graph.Data {
+	return graph.Data{
+		Sources: func() []graph.Source {
+			var list []graph.Source
+			for _, s := range sourceStatuses(aggregator.Sources) {
+				list = append(list, graph.Source{ID: s.ID, Name: s.Name, Logo: s.Logo, Homepage: s.Homepage, Enabled: s.Enabled})
+			}
+			return list
+		},
+		Headlines: func() []headline.Response {
+			cached, _ := aggregator.Headlines()
+			return cached.Body
+		},
+		History: store,
+		Archive: pages,
+		Feed:    feed,
 	}
 }
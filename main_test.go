@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/shaharia-lab/headlines/analytics"
+	"github.com/shaharia-lab/headlines/archive"
 	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
 )
 
 // MockNewsClient is a mock implementation of the NewsClient interface for testing
@@ -14,7 +22,7 @@ type MockNewsClient struct {
 	headlines []headline.NewsItem
 }
 
-func (m *MockNewsClient) GetHeadlines() (headline.Response, error) {
+func (m *MockNewsClient) GetHeadlines(ctx context.Context) (headline.Response, error) {
 	return headline.Response{
 		Source:    headline.SourceInfo{Name: "Mock Source", Logo: "http://mock.com/logo.png", Homepage: "http://mock.com"},
 		Headlines: m.headlines,
@@ -48,7 +56,7 @@ func TestHeadlinesHandler(t *testing.T) {
 
 	// Create a ResponseRecorder to record the response
 	rr := httptest.NewRecorder()
-	handler := headlinesHandler(sources)
+	handler := headlinesHandler(headline.NewAggregator(func() []headline.NewsClient { return sources }, headline.DefaultCachePolicy))
 
 	// Call the handler
 	handler.ServeHTTP(rr, req)
@@ -78,3 +86,299 @@ func TestHeadlinesHandler(t *testing.T) {
 		t.Errorf("Expected X-Cache header to be MISS, got %s", cacheHeader)
 	}
 }
+
+func TestSourcesHandler(t *testing.T) {
+	enabled, err := headline.New("mzamin", headline.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/sources", nil)
+	rr := httptest.NewRecorder()
+	sourcesHandler(func() []headline.NewsClient { return []headline.NewsClient{enabled} }).ServeHTTP(rr, req)
+
+	var response []sourceStatus
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+
+	if len(response) != len(headline.Available()) {
+		t.Fatalf("Expected %d sources, got %d", len(headline.Available()), len(response))
+	}
+	if response[0].ID != "mzamin" || !response[0].Enabled {
+		t.Errorf("Expected the enabled mzamin source first, got %+v", response[0])
+	}
+	for _, s := range response[1:] {
+		if s.Enabled {
+			t.Errorf("Expected source %s to be disabled", s.ID)
+		}
+	}
+}
+
+func TestHeadlinesHandlerCategory(t *testing.T) {
+	sources := []headline.NewsClient{&MockNewsClient{
+		headlines: []headline.NewsItem{
+			{Title: "Test 1", URL: "http://test1.com/sports/1", Category: headline.CategorySports},
+			{Title: "Test 2", URL: "http://test1.com/politics/2", Category: headline.CategoryPolitics},
+		},
+	}}
+	handler := headlinesHandler(headline.NewAggregator(func() []headline.NewsClient { return sources }, headline.DefaultCachePolicy))
+
+	// Bengali section names are mapped to the taxonomy
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/headlines?category=খেলা", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var response []headline.Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if len(response) != 1 || len(response[0].Headlines) != 1 || response[0].Headlines[0].Title != "Test 1" {
+		t.Errorf("Expected only the sports headline, got %+v", response)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/api/headlines?category=weather", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an unknown category, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestTopHandler(t *testing.T) {
+	store, _ := history.Open("", 0)
+	store.Record(time.Now(), []headline.Response{{
+		Source: headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{
+			{Title: "Test 1", URL: "http://test1.com", Rank: 1, Prominence: headline.ProminenceLead},
+			{Title: "Test 2", URL: "http://test2.com", Rank: 2, Prominence: headline.ProminenceTop},
+		},
+	}})
+
+	rr := httptest.NewRecorder()
+	topHandler(store).ServeHTTP(rr, httptest.NewRequest("GET", "/api/top?limit=1", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var stories []analytics.TopStory
+	if err := json.Unmarshal(rr.Body.Bytes(), &stories); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if len(stories) != 1 || stories[0].Title != "Test 1" {
+		t.Errorf("Expected the lead story only, got %+v", stories)
+	}
+
+	rr = httptest.NewRecorder()
+	topHandler(store).ServeHTTP(rr, httptest.NewRequest("GET", "/api/top?window=yesterday", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid window, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestTrendsHandler(t *testing.T) {
+	store, _ := history.Open("", 0)
+	store.Record(time.Now(), []headline.Response{{
+		Source: headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{
+			{Title: "Padma bridge opens", URL: "http://test1.com"},
+			{Title: "Crowds at Padma bridge", URL: "http://test2.com"},
+		},
+	}})
+
+	rr := httptest.NewRecorder()
+	trendsHandler(store).ServeHTTP(rr, httptest.NewRequest("GET", "/api/trends?window=1h", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var report analytics.TrendReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if report.Headlines != 2 || len(report.Terms) != 1 || report.Terms[0].Term != "padma bridge" {
+		t.Errorf("Expected padma bridge to trend, got %+v", report)
+	}
+}
+
+func TestSnapshotsHandler(t *testing.T) {
+	pages, err := archive.Open(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("Error opening archive: %v", err)
+	}
+	defer pages.Close()
+
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	pages.PageHook("mzamin")("https://mzamin.com/", []byte("<html>page</html>"))
+	pages.Record(at, []headline.Response{{
+		Source:    headline.SourceInfo{ID: "mzamin"},
+		Headlines: []headline.NewsItem{{Title: "Test 1", URL: "http://test1.com"}},
+	}})
+
+	router := chi.NewRouter()
+	router.Get("/api/snapshots", snapshotsHandler(pages, func() []headline.NewsClient { return nil }))
+	router.Get("/api/snapshots/pages/{hash}", snapshotPageHandler(pages))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/snapshots?at=2024-01-01T12:30:00Z", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+
+	var snapshots []snapshotResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &snapshots); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Source.Name != "মানবজমিন" || snapshots[0].Headlines[0].Title != "Test 1" || len(snapshots[0].Pages) != 1 {
+		t.Fatalf("Expected the mzamin snapshot, got %+v", snapshots)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", snapshots[0].Pages[0].Href, nil))
+	if rr.Body.String() != "<html>page</html>" || rr.Header().Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("Expected the sandboxed page, got %q with headers %v", rr.Body.String(), rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/snapshots?at=yesterday", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid time, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestArticleRevisionsHandler(t *testing.T) {
+	store, _ := history.Open("", 0)
+	for i, title := range []string{"Ten killed", "Twelve killed"} {
+		store.Record(time.Now().Add(time.Duration(i-2)*time.Minute), []headline.Response{{
+			Source:    headline.SourceInfo{ID: "test"},
+			Headlines: []headline.NewsItem{{Title: title, URL: "http://test1.com"}},
+		}})
+	}
+
+	router := chi.NewRouter()
+	router.Get("/api/articles/{id}/revisions", articleRevisionsHandler(store))
+	router.Get("/api/edits", editsHandler(store))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/articles/"+history.ArticleID("http://test1.com")+"/revisions", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var response struct {
+		Revisions []revision `json:"revisions"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if len(response.Revisions) != 2 || response.Revisions[0].Diff != nil || len(response.Revisions[1].Diff) != 3 {
+		t.Errorf("Expected 2 revisions with a diff on the second, got %+v", response.Revisions)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/edits", nil))
+	var edits []edit
+	if err := json.Unmarshal(rr.Body.Bytes(), &edits); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if len(edits) != 1 || edits[0].PreviousTitle != "Ten killed" || edits[0].Title != "Twelve killed" {
+		t.Errorf("Expected the edit in the feed, got %+v", edits)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/api/articles/unknown/revisions", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown article, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestLifecyclesHandler(t *testing.T) {
+	store, _ := history.Open("", 0)
+	now := time.Now()
+	store.Record(now.Add(-2*time.Hour), []headline.Response{{
+		Source:    headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{{Title: "Test 1", URL: "http://test1.com", Rank: 1}},
+	}})
+	store.Record(now.Add(-time.Hour), []headline.Response{{
+		Source:    headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{{Title: "Test 2", URL: "http://test2.com", Rank: 1}},
+	}})
+
+	replay := newLifecycleReplay(store)
+	rr := httptest.NewRecorder()
+	lifecyclesHandler(replay).ServeHTTP(rr, httptest.NewRequest("GET", "/api/lifecycles?window=3h", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusOK)
+	}
+	var lifecycles []analytics.Lifecycle
+	if err := json.Unmarshal(rr.Body.Bytes(), &lifecycles); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if len(lifecycles) != 2 || lifecycles[0].Title != "Test 2" || lifecycles[0].Left != nil || lifecycles[1].Left == nil {
+		t.Errorf("Expected the replaced story to have left, got %+v", lifecycles)
+	}
+
+	rr = httptest.NewRecorder()
+	dwellHandler(replay).ServeHTTP(rr, httptest.NewRequest("GET", "/api/dwell?window=3h", nil))
+	var reports []analytics.DwellReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &reports); err != nil {
+		t.Fatalf("Could not parse response body: %v", err)
+	}
+	if len(reports) != 1 || reports[0].Stories != 2 || reports[0].Entered != 1 || reports[0].Left != 1 {
+		t.Errorf("Unexpected dwell report %+v", reports)
+	}
+
+	// The lifecycles are replayed again once the history changed
+	store.Record(now, []headline.Response{{
+		Source:    headline.SourceInfo{ID: "test"},
+		Headlines: []headline.NewsItem{{Title: "Test 3", URL: "http://test3.com", Rank: 1}},
+	}})
+	if lifecycles := lifecyclesWithin(replay, 3*time.Hour, ""); len(lifecycles) != 3 || lifecycles[0].Title != "Test 3" {
+		t.Errorf("Expected the new story, got %+v", lifecycles)
+	}
+
+	rr = httptest.NewRecorder()
+	dwellHandler(replay).ServeHTTP(rr, httptest.NewRequest("GET", "/api/dwell?window=soon", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid window, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestServeShutdown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("done"))
+	})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- serve(ctx, server, listener, time.Second) }()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	// The request in flight completes after the shutdown started
+	<-started
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Expected a graceful shutdown, got %v", err)
+	}
+	if got := <-body; got != "done" {
+		t.Errorf("Expected the request in flight to complete, got %q", got)
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shaharia-lab/headlines/archive"
+	"github.com/shaharia-lab/headlines/config"
+	"github.com/shaharia-lab/headlines/graph"
+	"github.com/shaharia-lab/headlines/headline"
+	"github.com/shaharia-lab/headlines/history"
+)
+
+// sourceSet holds the currently enabled news sources and swaps them atomically on reload
+type sourceSet struct {
+	mu      sync.RWMutex
+	sources []headline.NewsClient
+	configs []config.SourceConfig
+
+	// reloadMu serializes reloads triggered by signals and file changes
+	reloadMu   sync.Mutex
+	load       func() (*config.Config, error)
+	httpClient *headline.CachingHTTPClient
+	breaker    config.CircuitBreakerConfig
+	archive    *archive.Archive
+	onChange   func()
+}
+
+// newSourceSet creates a sourceSet from the initial configuration.
+// load is called on every reload to resolve the configuration again. The pages fetched by the
+// sources are stored in pages unless it is nil.
+func newSourceSet(cfg *config.Config, load func() (*config.Config, error), httpClient *headline.CachingHTTPClient, pages *archive.Archive) (*sourceSet, error) {
+	s := &sourceSet{
+		load:       load,
+		httpClient: httpClient,
+		breaker:    cfg.Scraper.CircuitBreaker,
+		archive:    pages,
+	}
+	if err := s.apply(cfg.EnabledSources()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Sources returns the currently enabled news sources
+func (s *sourceSet) Sources() []headline.NewsClient {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sources
+}
+
+// Reload resolves the configuration again and swaps in the new sources.
+// If the new configuration is invalid the current sources are kept.
+func (s *sourceSet) Reload() error {
+	cfg, err := s.load()
+	if err != nil {
+		return fmt.Errorf("reload failed, keeping current sources: %v", err)
+	}
+	if err := s.apply(cfg.EnabledSources()); err != nil {
+		return fmt.Errorf("reload failed, keeping current sources: %v", err)
+	}
+	return nil
+}
+
+// apply swaps in the sources for configs. Sources whose configuration did not change are kept,
+// so that their state such as the circuit breaker survives the reload.
+func (s *sourceSet) apply(configs []config.SourceConfig) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	s.mu.RLock()
+	current := make(map[string]int, len(s.configs))
+	for i, sc := range s.configs {
+		current[sc.ID] = i
+	}
+	currentConfigs, currentSources := s.configs, s.sources
+	s.mu.RUnlock()
+
+	sources := make([]headline.NewsClient, 0, len(configs))
+	for _, sc := range configs {
+		if i, ok := current[sc.ID]; ok && sameSourceConfig(currentConfigs[i], sc) {
+			sources = append(sources, currentSources[i])
+			continue
+		}
+		source, err := newSource(sc, s.httpClient, s.breaker, s.archive)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, source)
+	}
+
+	s.mu.Lock()
+	previous := s.configs
+	s.sources = sources
+	s.configs = configs
+	onChange := s.onChange
+	s.mu.Unlock()
+
+	if previous != nil {
+		logSourceChanges(previous, configs)
+		if onChange != nil {
+			onChange()
+		}
+	}
+	return nil
+}
+
+// sameSourceConfig reports whether two enabled source configurations are equal
+func sameSourceConfig(a, b config.SourceConfig) bool {
+	a.Enabled, b.Enabled = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+// OnChange registers a function called after the sources were swapped
+func (s *sourceSet) OnChange(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = f
+}
+
+// WatchSignals reloads the sources whenever the process receives SIGHUP
+func (s *sourceSet) WatchSignals(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	defer signal.Stop(sighup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sighup:
+			log.Printf("Received SIGHUP, reloading sources")
+			s.reloadAndLog()
+		}
+	}
+}
+
+// WatchFile reloads the sources whenever the modification time of the file at path changes
+func (s *sourceSet) WatchFile(ctx context.Context, path string, interval time.Duration) {
+	lastModified := modTime(path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			modified := modTime(path)
+			if modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+			log.Printf("Config file %s changed, reloading sources", path)
+			s.reloadAndLog()
+		}
+	}
+}
+
+func (s *sourceSet) reloadAndLog() {
+	if err := s.Reload(); err != nil {
+		log.Printf("Error: %v", err)
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func logSourceChanges(previous, current []config.SourceConfig) {
+	before := make(map[string]config.SourceConfig, len(previous))
+	for _, sc := range previous {
+		before[sc.ID] = sc
+	}
+
+	for _, sc := range current {
+		old, ok := before[sc.ID]
+		switch {
+		case !ok:
+			log.Printf("Source %s added", sc.ID)
+		case !sameSourceConfig(old, sc):
+			log.Printf("Source %s changed", sc.ID)
+		}
+		delete(before, sc.ID)
+	}
+	for id := range before {
+		log.Printf("Source %s removed", id)
+	}
+}
+
+// poller refreshes the cached headlines in the background
+type poller struct {
+	aggregator *headline.Aggregator
+	interval   time.Duration
+	trigger    chan struct{}
+	// history and archive record every refresh when set
+	history *history.Store
+	archive *archive.Archive
+	// feed publishes the new headlines of every refresh to the GraphQL subscriptions when set
+	feed *graph.Feed
+}
+
+func newPoller(aggregator *headline.Aggregator, interval time.Duration, store *history.Store, pages *archive.Archive, feed *graph.Feed) *poller {
+	return &poller{
+		aggregator: aggregator,
+		interval:   interval,
+		trigger:    make(chan struct{}, 1),
+		history:    store,
+		archive:    pages,
+		feed:       feed,
+	}
+}
+
+// Trigger requests an immediate refresh
+func (p *poller) Trigger() {
+	select {
+	case p.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Run refreshes the headlines every interval until ctx is cancelled
+func (p *poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	p.refresh(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.refresh(ctx)
+		case <-p.trigger:
+			p.refresh(ctx)
+		}
+	}
+}
+
+func (p *poller) refresh(ctx context.Context) {
+	headlines := p.aggregator.Refresh(ctx).Body
+	if ctx.Err() != nil {
+		// The fetches were cancelled by the shutdown, so the sources did not fail
+		return
+	}
+	now := time.Now()
+	if p.history != nil {
+		if err := p.history.Record(now, headlines); err != nil {
+			log.Printf("Failed to record history: %v", err)
+		}
+	}
+	if p.archive != nil {
+		if err := p.archive.Record(now, headlines); err != nil {
+			log.Printf("Failed to record archive: %v", err)
+		}
+	}
+	if p.feed != nil {
+		p.feed.Publish(headlines)
+	}
+}
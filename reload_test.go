@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shaharia-lab/headlines/config"
+	"github.com/shaharia-lab/headlines/headline"
+)
+
+func TestSourceSetReload(t *testing.T) {
+	httpClient := headline.NewCachingHTTPClient(time.Second, "test-agent")
+
+	next := config.Default()
+	var loadErr error
+	sources, err := newSourceSet(config.Default(), func() (*config.Config, error) {
+		return next, loadErr
+	}, httpClient, nil)
+	if err != nil {
+		t.Fatalf("Error creating source set: %v", err)
+	}
+
+	changes := 0
+	sources.OnChange(func() { changes++ })
+
+	if len(sources.Sources()) != 3 {
+		t.Fatalf("Expected 3 sources, got %d", len(sources.Sources()))
+	}
+
+	// Unchanged sources are kept across reloads
+	mzamin := sources.Sources()[1]
+
+	// Removing a source swaps in the smaller set
+	if err := next.EnableOnly([]string{"mzamin"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sources.Reload(); err != nil {
+		t.Fatalf("Unexpected reload error: %v", err)
+	}
+	if got := sources.Sources(); len(got) != 1 || got[0].SourceInfo().Name != "মানবজমিন" {
+		t.Errorf("Expected only mzamin after reload, got %d sources", len(got))
+	}
+	if changes != 1 {
+		t.Errorf("Expected 1 change notification, got %d", changes)
+	}
+	if sources.Sources()[0] != mzamin {
+		t.Error("Expected the unchanged mzamin source to be kept")
+	}
+
+	// A failed reload keeps the current sources
+	loadErr = errors.New("server.port must be between 1 and 65535")
+	if err := sources.Reload(); err == nil {
+		t.Error("Expected reload to fail")
+	}
+	if len(sources.Sources()) != 1 {
+		t.Errorf("Expected current sources to be kept, got %d sources", len(sources.Sources()))
+	}
+
+	// An unknown source is rejected as well
+	loadErr = nil
+	next = config.Default()
+	next.Sources = append(next.Sources, config.SourceConfig{ID: "unknown", URL: "https://example.com/"})
+	if err := sources.Reload(); err == nil {
+		t.Error("Expected reload with an unknown source to fail")
+	}
+	if len(sources.Sources()) != 1 || changes != 1 {
+		t.Errorf("Expected current sources to be kept, got %d sources", len(sources.Sources()))
+	}
+}